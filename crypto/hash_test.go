@@ -50,3 +50,33 @@ func accountCheck(v interface{}) Hash {
 func (s *HashSuite) TestHashes(c *C) {
 	accountTests.Test(c)
 }
+
+func (s *HashSuite) TestXAddress(c *C) {
+	account := accountCheck("iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf").Payload()
+	one, max := uint32(1), uint32(4294967295)
+	for _, test := range []struct {
+		tag     *uint32
+		test    bool
+		address string
+	}{
+		{nil, false, "XVLhHMPHU98es4dbozjVtdWzViDjtV5fdx1mHp98tDMoQXb"},
+		{&one, false, "XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC"},
+		{&max, false, "XVLhHMPHU98es4dbozjVtdWzViDjtV18pX8yuPT7y4xaEHr"},
+		{nil, true, "TVE26TYGhfLC7tQDno7G8dGtxSkYQn49b3qD26PK7FcGSKE"},
+		{&one, true, "TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw"},
+	} {
+		address, err := EncodeXAddress(account, test.tag, test.test)
+		c.Assert(err, IsNil)
+		c.Check(address, Equals, test.address)
+		c.Check(IsXAddress(address), Equals, true)
+		id, tag, network, err := DecodeXAddress(address)
+		c.Assert(err, IsNil)
+		c.Check(id, DeepEquals, account)
+		c.Check(tag, DeepEquals, test.tag)
+		c.Check(network, Equals, test.test)
+	}
+	_, _, _, err := DecodeXAddress("iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(err, ErrorMatches, "Bad X-address length: .*")
+	_, err = EncodeXAddress(account[1:], nil, false)
+	c.Check(err, ErrorMatches, "Account id is wrong size.*")
+}
@@ -3,7 +3,9 @@ package crypto
 import (
 	"encoding/hex"
 	"fmt"
+	"math/big"
 
+	"github.com/btcsuite/btcd/btcec"
 	. "gopkg.in/check.v1"
 	"testing"
 )
@@ -118,3 +120,38 @@ func Test0(t *testing.T) {
 		t.Error("!= snoPBiXtMeMyMHUVTgbuqAfg1SUTb")
 	}
 }
+
+func (s *KeySuite) TestCanonicalSignatures(c *C) {
+	key, err := NewECDSAKey(h2b("71ED064155FFADFA38782C5E0158CB26"))
+	c.Assert(err, IsNil)
+	var sequence uint32
+	for i := 0; i < 32; i++ {
+		hash := Sha512Half([]byte{byte(i)})
+		sig, err := Sign(key.Private(&sequence), hash, nil)
+		c.Assert(err, IsNil)
+		c.Assert(CheckCanonical(key.Public(&sequence), sig), Equals, FullyCanonical)
+
+		// The high S twin verifies but is only Canonical
+		parsed, err := btcec.ParseDERSignature(sig, btcec.S256())
+		c.Assert(err, IsNil)
+		parsed.S.Sub(btcec.S256().N, parsed.S)
+		twin := append([]byte{0x30, 0}, encodeDERInteger(parsed.R)...)
+		twin = append(twin, encodeDERInteger(parsed.S)...)
+		twin[1] = byte(len(twin) - 2)
+		c.Check(CheckCanonical(key.Public(&sequence), twin), Equals, Canonical)
+		ok, err := Verify(key.Public(&sequence), hash, nil, twin)
+		c.Check(ok, Equals, true)
+		c.Check(err, IsNil)
+	}
+	c.Check(CheckCanonical(key.Public(&sequence), []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x81}), Equals, NonCanonical)
+	c.Check(CheckCanonical(key.Public(&sequence), []byte{0x30, 0x07, 0x02, 0x02, 0x00, 0x01, 0x02, 0x01, 0x01}), Equals, NonCanonical)
+	c.Check(CheckCanonical(key.Public(&sequence), []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01}), Equals, FullyCanonical)
+}
+
+func encodeDERInteger(i *big.Int) []byte {
+	b := i.Bytes()
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return append([]byte{0x02, byte(len(b))}, b...)
+}
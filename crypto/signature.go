@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/agl/ed25519"
 	"github.com/btcsuite/btcd/btcec"
@@ -54,13 +55,17 @@ func verifyEd25519(pubKey, signature, msg []byte) (bool, error) {
 	}
 }
 
-// Returns DER encoded signature from input hash
+// Returns fully canonical DER encoded signature from input hash
 func signECDSA(privateKey, hash []byte) ([]byte, error) {
 	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), privateKey)
 	sig, err := priv.Sign(hash)
 	if err != nil {
 		return nil, err
 	}
+	// Of the two valid values of S only the lower one is fully canonical
+	if sig.S.Cmp(halfOrder) > 0 {
+		sig.S = new(big.Int).Sub(btcec.S256().N, sig.S)
+	}
 	return sig.Serialize(), nil
 }
 
@@ -76,3 +81,98 @@ func verifyECDSA(pubKey, signature, hash []byte) (bool, error) {
 	}
 	return sig.Verify(hash, pk), nil
 }
+
+type Canonicality int
+
+const (
+	NonCanonical Canonicality = iota
+	Canonical
+	FullyCanonical
+)
+
+var (
+	halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+	// Order of the ed25519 base point
+	ed25519Order, _ = new(big.Int).SetString("1000000000000000000000000000000014DEF9DEA2F79CD65812631A5CF5D3ED", 16)
+)
+
+var canonicalityNames = [...]string{
+	NonCanonical:   "NonCanonical",
+	Canonical:      "Canonical",
+	FullyCanonical: "FullyCanonical",
+}
+
+func (c Canonicality) String() string {
+	return canonicalityNames[c]
+}
+
+// CheckCanonical classifies a signature in the same way as rippled.
+// ECDSA signatures must be strict DER with R and S below the curve
+// order to be Canonical, and additionally have S in the lower half of
+// the range to be FullyCanonical. Ed25519 signatures are either
+// FullyCanonical or NonCanonical depending on S being reduced.
+func CheckCanonical(publicKey, signature []byte) Canonicality {
+	if len(publicKey) > 0 && publicKey[0] == 0xED {
+		if len(signature) != ed25519.SignatureSize {
+			return NonCanonical
+		}
+		// S is little endian
+		var s [32]byte
+		for i := range s {
+			s[i] = signature[63-i]
+		}
+		if new(big.Int).SetBytes(s[:]).Cmp(ed25519Order) >= 0 {
+			return NonCanonical
+		}
+		return FullyCanonical
+	}
+	r, s, ok := parseStrictDER(signature)
+	switch {
+	case !ok:
+		return NonCanonical
+	case r.Cmp(btcec.S256().N) >= 0 || s.Cmp(btcec.S256().N) >= 0:
+		return NonCanonical
+	case s.Cmp(halfOrder) > 0:
+		return Canonical
+	default:
+		return FullyCanonical
+	}
+}
+
+// parseStrictDER accepts only the minimal DER encoding of two positive integers
+func parseStrictDER(sig []byte) (*big.Int, *big.Int, bool) {
+	if len(sig) < 8 || len(sig) > 72 || sig[0] != 0x30 || int(sig[1]) != len(sig)-2 {
+		return nil, nil, false
+	}
+	r, rest, ok := parseDERInteger(sig[2:])
+	if !ok {
+		return nil, nil, false
+	}
+	s, rest, ok := parseDERInteger(rest)
+	if !ok || len(rest) != 0 {
+		return nil, nil, false
+	}
+	return r, s, true
+}
+
+func parseDERInteger(b []byte) (*big.Int, []byte, bool) {
+	if len(b) < 3 || b[0] != 0x02 {
+		return nil, nil, false
+	}
+	n := int(b[1])
+	switch {
+	case n < 1 || n > 33 || len(b) < n+2:
+		return nil, nil, false
+	case b[2]&0x80 != 0:
+		// Negative
+		return nil, nil, false
+	case n > 1 && b[2] == 0 && b[3]&0x80 == 0:
+		// Excess padding
+		return nil, nil, false
+	}
+	i := new(big.Int).SetBytes(b[2 : n+2])
+	if i.Sign() == 0 {
+		return nil, nil, false
+	}
+	return i, b[n+2:], true
+}
@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// X-addresses pack an account id with an optional destination tag:
+// a two byte network prefix, the account id, a flag byte which is 1 when
+// there is a tag, the tag as little endian uint64 and a checksum
+var (
+	xAddressMain = []byte{0x05, 0x44}
+	xAddressTest = []byte{0x04, 0x93}
+)
+
+const xAddressLength = 2 + 20 + 1 + 8
+
+// EncodeXAddress returns the X-address of an account id and tag, which
+// may be nil, for the main network or, if test is set, the test network
+func EncodeXAddress(accountId []byte, tag *uint32, test bool) (string, error) {
+	if len(accountId) != 20 {
+		return "", fmt.Errorf("Account id is wrong size, expected: 20 got: %d", len(accountId))
+	}
+	b := make([]byte, 0, xAddressLength)
+	if test {
+		b = append(b, xAddressTest...)
+	} else {
+		b = append(b, xAddressMain...)
+	}
+	b = append(b, accountId...)
+	var flag byte
+	var t [8]byte
+	if tag != nil {
+		flag = 1
+		binary.LittleEndian.PutUint32(t[:], *tag)
+	}
+	b = append(append(b, flag), t[:]...)
+	return Base58Encode(b, ALPHABET), nil
+}
+
+// DecodeXAddress returns the account id, tag and network of an X-address
+func DecodeXAddress(s string) (accountId []byte, tag *uint32, test bool, err error) {
+	b, err := Base58Decode(s, ALPHABET)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	b = b[:len(b)-4]
+	if len(b) != xAddressLength {
+		return nil, nil, false, fmt.Errorf("Bad X-address length: %s", s)
+	}
+	switch {
+	case bytes.Equal(b[:2], xAddressMain):
+	case bytes.Equal(b[:2], xAddressTest):
+		test = true
+	default:
+		return nil, nil, false, fmt.Errorf("Bad X-address prefix: %s", s)
+	}
+	accountId = b[2:22]
+	t := binary.LittleEndian.Uint64(b[23:])
+	switch {
+	case b[22] > 1:
+		return nil, nil, false, fmt.Errorf("Bad X-address flags: %s", s)
+	case t > 0xFFFFFFFF:
+		return nil, nil, false, fmt.Errorf("X-address tag out of range: %s", s)
+	case b[22] == 0 && t != 0:
+		return nil, nil, false, fmt.Errorf("X-address has tag without flag: %s", s)
+	case b[22] == 1:
+		tag = new(uint32)
+		*tag = uint32(t)
+	}
+	return accountId, tag, test, nil
+}
+
+// IsXAddress reports whether s looks like an X-address rather than a
+// classic address, without checking that it decodes
+func IsXAddress(s string) bool {
+	return len(s) > 0 && (s[0] == 'X' || s[0] == 'T')
+}
@@ -175,6 +175,26 @@ func (a Amount) Multiply(b *Amount) (*Amount, error) {
 	return applyInterestPair(a, b, Amount.multiply)
 }
 
+// MulRound returns a*b in the given currency and issuer, rounded as
+// rippled's mulRound would. See Value.MulRound.
+func (a Amount) MulRound(b *Amount, currency Currency, issuer Account, roundUp bool) (*Amount, error) {
+	product, err := a.Value.MulRound(*b.Value, currency.IsNative(), roundUp)
+	if err != nil {
+		return nil, err
+	}
+	return newAmount(product, currency, issuer), nil
+}
+
+// DivRound returns a/b in the given currency and issuer, rounded as
+// rippled's divRound would. See Value.DivRound.
+func (a Amount) DivRound(b *Amount, currency Currency, issuer Account, roundUp bool) (*Amount, error) {
+	quotient, err := a.Value.DivRound(*b.Value, currency.IsNative(), roundUp)
+	if err != nil {
+		return nil, err
+	}
+	return newAmount(quotient, currency, issuer), nil
+}
+
 // Ratio returns the ratio between a and b.
 // Returns a zero value when division is impossible
 func (a Amount) Ratio(b Amount) *Value {
@@ -225,21 +245,28 @@ func (a Amount) Machine() string {
 	}
 }
 
+// NewExchangeRate returns the quality of an offer paying a to get b,
+// encoded as rippled's getRate does, with the exponent in the top
+// byte so that better (lower) qualities sort first.
 func NewExchangeRate(a, b *Amount) (ExchangeRate, error) {
 	if b.IsZero() {
 		return 0, nil
 	}
-	rate, err := a.Divide(b)
+	num, err := a.Value.NonNative()
+	if err != nil {
+		return 0, err
+	}
+	rate, err := num.Divide(*b.Value)
 	if err != nil {
 		return 0, err
 	}
 	if rate.IsZero() {
 		return 0, nil
 	}
-	if rate.offset >= -100 || rate.offset <= 155 {
-		panic("Impossible Rate")
+	if rate.offset < -100 || rate.offset > 155 {
+		return 0, fmt.Errorf("Impossible rate: %s", rate.debug())
 	}
-	return ExchangeRate(uint64(rate.offset+100)<<54 | uint64(rate.num)), nil
+	return ExchangeRate(uint64(rate.offset+100)<<56 | rate.num), nil
 }
 
 func (e *ExchangeRate) Bytes() []byte {
@@ -250,3 +277,46 @@ func (e *ExchangeRate) Bytes() []byte {
 	binary.BigEndian.PutUint64(b, uint64(*e))
 	return b
 }
+
+// Value decodes the rate into the ratio of the amount paid to the
+// amount got, with native amounts counted in drips
+func (e ExchangeRate) Value() (*Value, error) {
+	if e == 0 {
+		return zeroNonNative.Clone(), nil
+	}
+	v := newValue(false, false, uint64(e)&(1<<56-1), int64(e>>56)-100)
+	return v, v.canonicalise()
+}
+
+// Price returns the rate as the number of pays needed for one gets,
+// with native amounts counted in ICC rather than drips
+func (e ExchangeRate) Price(pays, gets Currency) (*Value, error) {
+	v, err := e.Value()
+	if err != nil || v.IsZero() {
+		return v, err
+	}
+	if pays.IsNative() {
+		v.offset -= 6
+	}
+	if gets.IsNative() {
+		v.offset += 6
+	}
+	return v, v.canonicalise()
+}
+
+// Compare returns -1 if e is a better quality than other, 0 if they
+// are equal and +1 if it is worse. Lower rates are better for the taker.
+func (e ExchangeRate) Compare(other ExchangeRate) int {
+	switch {
+	case e < other:
+		return -1
+	case e > other:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (e ExchangeRate) Less(other ExchangeRate) bool {
+	return e < other
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"encoding/hex"
 	"fmt"
 	"testing"
 
@@ -168,6 +169,43 @@ func (s *AmountSuite) TestAmount(c *C) {
 	amountTests.Test(c)
 }
 
+func (s *AmountSuite) TestExchangeRate(c *C) {
+	// The XRP/BTC.Bitstamp book from transaction_offercreate.json
+	var btc, bitstamp, zero Hash160
+	copy(btc[12:], "BTC")
+	_, err := hex.Decode(bitstamp[:], []byte("0A20B3C85F482532A9578DBB3950B85CA06594D1"))
+	c.Assert(err, IsNil)
+	book, err := GetBookIndex(zero, btc, zero, bitstamp)
+	c.Assert(err, IsNil)
+	c.Check(book.String(), Equals, "7B73A610A009249B0CC0D4311E8BA7927B5A34D86634581C0000000000000000")
+
+	// A CNY/XRP offer from transactions_stream.json
+	rate, err := NewExchangeRate(amountCheck("174.72/CNY"), amountCheck("6400064000"))
+	c.Assert(err, IsNil)
+	var base Hash256
+	directory := GetQualityIndex(base, rate)
+	c.Check(directory.String(), Equals, "0000000000000000000000000000000000000000000000004D09B2E54D0BD965")
+	c.Check(GetQuality(*directory), Equals, rate)
+	value, err := rate.Value()
+	c.Assert(err, IsNil)
+	c.Check(value.String(), Equals, "0.00000002729972700272997")
+	price, err := rate.Price(Currency(btc), zeroCurrency)
+	c.Assert(err, IsNil)
+	c.Check(price.String(), Equals, "0.02729972700272997")
+
+	worse, err := NewExchangeRate(amountCheck("175/CNY"), amountCheck("6400064000"))
+	c.Assert(err, IsNil)
+	better := rate
+	rate = worse
+	c.Check(better.Less(rate), Equals, true)
+	c.Check(better.Compare(rate), Equals, -1)
+	c.Check(rate.Compare(rate), Equals, 0)
+	c.Check(ExchangeRate(0).Less(better), Equals, true)
+	zeroRate, err := ExchangeRate(0).Value()
+	c.Assert(err, IsNil)
+	c.Check(zeroRate.IsZero(), Equals, true)
+}
+
 func ExampleValue_Add() {
 	v1, _ := NewValue("100", false)
 	v2, _ := NewValue("200.199", false)
@@ -0,0 +1,397 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Builder assembles a transaction one field at a time, remembering the
+// first mistake so that the calls can be chained. Build checks the
+// result against the transaction's format before it is signed.
+//
+//	tx, err := NewPayment(account, destination, amount).
+//		Sequence(12).
+//		Fee(fee).
+//		Set("DestinationTag", uint32(7)).
+//		Build()
+type Builder struct {
+	tx  Transaction
+	set map[string]bool
+	err error
+}
+
+// NewBuilder returns a Builder for an empty transaction of typ sent by account
+func NewBuilder(typ TransactionType, account Account) *Builder {
+	b := &Builder{set: make(map[string]bool)}
+	factory := txFactory(typ)
+	if factory == nil {
+		b.err = fmt.Errorf("Unknown TransactionType: %d", typ)
+		return b
+	}
+	b.tx = factory()
+	b.tx.GetBase().Account = account
+	b.set["TransactionType"], b.set["Account"] = true, true
+	return b
+}
+
+func NewPayment(account, destination Account, amount Amount) *Builder {
+	return NewBuilder(PAYMENT, account).Set("Destination", destination).Set("Amount", amount)
+}
+
+// NewPaymentTo returns a Builder for a Payment to a classic address or
+// an X-address, whose tag becomes the DestinationTag
+func NewPaymentTo(account Account, destination string, amount Amount) *Builder {
+	return NewBuilder(PAYMENT, account).Destination(destination).Set("Amount", amount)
+}
+
+func NewOfferCreate(account Account, takerPays, takerGets Amount) *Builder {
+	return NewBuilder(OFFER_CREATE, account).Set("TakerPays", takerPays).Set("TakerGets", takerGets)
+}
+
+func NewOfferCancel(account Account, offerSequence uint32) *Builder {
+	return NewBuilder(OFFER_CANCEL, account).Set("OfferSequence", offerSequence)
+}
+
+func NewTrustSet(account Account, limit Amount) *Builder {
+	return NewBuilder(TRUST_SET, account).Set("LimitAmount", limit)
+}
+
+func NewAccountSet(account Account) *Builder {
+	return NewBuilder(ACCOUNT_SET, account)
+}
+
+func NewSetRegularKey(account Account, key *RegularKey) *Builder {
+	b := NewBuilder(SET_REGULAR_KEY, account)
+	if key != nil {
+		b.Set("RegularKey", *key)
+	}
+	return b
+}
+
+func NewAccountDelete(account, destination Account) *Builder {
+	return NewBuilder(ACCOUNT_DELETE, account).Set("Destination", destination)
+}
+
+// Destination sets the Destination to a classic address or a main
+// network X-address, in which case any tag it has becomes the
+// DestinationTag
+func (b *Builder) Destination(address string) *Builder {
+	return b.destination(address, false)
+}
+
+// TestnetDestination is Destination but also accepts an X-address for
+// the test network
+func (b *Builder) TestnetDestination(address string) *Builder {
+	return b.destination(address, true)
+}
+
+func (b *Builder) destination(address string, test bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	destination, err := NewTaggedAccount(address)
+	if err == nil {
+		err = destination.checkNetwork(test)
+	}
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.Set("Destination", destination.Account)
+	if destination.Tag != nil {
+		b.Set("DestinationTag", *destination.Tag)
+	}
+	return b
+}
+
+func (b *Builder) Sequence(sequence uint32) *Builder {
+	return b.Set("Sequence", sequence)
+}
+
+func (b *Builder) Fee(fee Value) *Builder {
+	return b.Set("Fee", fee)
+}
+
+func (b *Builder) LastLedgerSequence(sequence uint32) *Builder {
+	return b.Set("LastLedgerSequence", sequence)
+}
+
+func (b *Builder) SourceTag(tag uint32) *Builder {
+	return b.Set("SourceTag", tag)
+}
+
+// Flags adds flags to those already set
+func (b *Builder) Flags(flags TransactionFlag) *Builder {
+	if b.err != nil {
+		return b
+	}
+	base := b.tx.GetBase()
+	if base.Flags == nil {
+		base.Flags = new(TransactionFlag)
+	}
+	*base.Flags |= flags
+	b.set["Flags"] = true
+	return b
+}
+
+// SetFlag sets the AccountSetFlag of an AccountSet
+func (b *Builder) SetFlag(flag AccountSetFlag) *Builder {
+	return b.Set("SetFlag", flag)
+}
+
+// ClearFlag sets the AccountSetFlag an AccountSet clears
+func (b *Builder) ClearFlag(flag AccountSetFlag) *Builder {
+	return b.Set("ClearFlag", flag)
+}
+
+// Memo appends a memo
+func (b *Builder) Memo(memo Memo) *Builder {
+	if b.err == nil {
+		b.tx.GetBase().Memos = append(b.tx.GetBase().Memos, memo)
+		b.set["Memos"] = true
+	}
+	return b
+}
+
+// Set assigns value to the named field, which must be part of the
+// transaction's format. Optional fields may be given by value.
+func (b *Builder) Set(name string, value interface{}) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if _, ok := txFieldRequirement(b.tx.GetTransactionType(), name); !ok {
+		b.err = fmt.Errorf("%s has no field %s", b.tx.GetType(), name)
+		return b
+	}
+	field := fieldByName(reflect.ValueOf(b.tx).Elem(), name)
+	v := reflect.ValueOf(value)
+	switch {
+	case !field.IsValid():
+		b.err = fmt.Errorf("%s has no field %s", b.tx.GetType(), name)
+	case v.IsValid() && v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case v.IsValid() && field.Kind() == reflect.Ptr && v.Type().AssignableTo(field.Type().Elem()):
+		field.Set(reflect.New(field.Type().Elem()))
+		field.Elem().Set(v)
+	default:
+		b.err = fmt.Errorf("Cannot set %s to %T", name, value)
+	}
+	b.set[name] = b.err == nil
+	return b
+}
+
+// Build returns a copy of the transaction once it passes
+// ValidateTransaction, so that later calls to the Builder do not change
+// a transaction already built. Required numbers and values, which can
+// not be told apart from zero, must have been set explicitly.
+func (b *Builder) Build() (Transaction, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	var (
+		typ     = b.tx.GetTransactionType()
+		v       = reflect.ValueOf(b.tx).Elem()
+		missing []string
+	)
+	for _, format := range []map[string]fieldRequirement{commonTxFormat, txFormats[typ]} {
+		for name, requirement := range format {
+			if requirement == soeRequired && isNumeric(fieldByName(v, name)) && !b.set[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%s missing required fields: %s", b.tx.GetType(), strings.Join(missing, ", "))
+	}
+	if err := ValidateTransaction(b.tx); err != nil {
+		return nil, err
+	}
+	_, raw, err := Raw(b.tx)
+	if err != nil {
+		return nil, err
+	}
+	return ReadTransaction(bytes.NewReader(raw))
+}
+
+func txFieldRequirement(typ TransactionType, name string) (fieldRequirement, bool) {
+	if requirement, ok := commonTxFormat[name]; ok {
+		return requirement, true
+	}
+	requirement, ok := txFormats[typ][name]
+	return requirement, ok
+}
+
+func isNumeric(v reflect.Value) bool {
+	if _, ok := v.Interface().(Value); ok {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isMissing reports whether a field has not been given a value. Numbers
+// can not be told apart from zero and are never missing.
+func isMissing(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Array:
+		return reflect.Zero(v.Type()).Interface() == v.Interface()
+	case reflect.Struct:
+		if amount, ok := v.Interface().(Amount); ok {
+			return amount.Value == nil
+		}
+	}
+	return false
+}
+
+// ValidFlags returns every flag which applies to a transaction type
+func ValidFlags(typ TransactionType) TransactionFlag {
+	flags := TxCanonicalSignature
+	for _, n := range txFlagNames[typ] {
+		flags |= n.Flag
+	}
+	return flags
+}
+
+// ValidateTransaction checks that tx has every field its format requires
+// and no flags that do not apply to it, and that its amounts make sense.
+// It catches mistakes rippled would reject, but not those which depend
+// on the state of the ledger.
+func ValidateTransaction(tx Transaction) error {
+	typ := tx.GetTransactionType()
+	format, ok := txFormats[typ]
+	if !ok {
+		return fmt.Errorf("Unknown TransactionType: %d", typ)
+	}
+	v := reflect.ValueOf(tx).Elem()
+	var missing []string
+	for _, f := range []map[string]fieldRequirement{commonTxFormat, format} {
+		for name, requirement := range f {
+			field := fieldByName(v, name)
+			if !field.IsValid() {
+				return fmt.Errorf("%s has no field %s", tx.GetType(), name)
+			}
+			if requirement == soeRequired && isMissing(field) {
+				missing = append(missing, name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%s missing required fields: %s", tx.GetType(), strings.Join(missing, ", "))
+	}
+	base := tx.GetBase()
+	if base.Flags != nil {
+		if invalid := *base.Flags &^ ValidFlags(typ); invalid != 0 {
+			return fmt.Errorf("%s does not accept flags: %s", tx.GetType(), invalid)
+		}
+	}
+	if !base.Fee.IsNative() || base.Fee.IsNegative() {
+		return fmt.Errorf("Fee must be a non-negative native amount: %s", base.Fee)
+	}
+	if err := base.Memos.Validate(); err != nil {
+		return err
+	}
+	switch tx := tx.(type) {
+	case *Payment:
+		return validatePayment(tx)
+	case *OfferCreate:
+		return validateOfferCreate(tx)
+	case *TrustSet:
+		if err := checkAmount("LimitAmount", tx.LimitAmount, false); err != nil {
+			return err
+		}
+		switch {
+		case tx.LimitAmount.IsNative():
+			return fmt.Errorf("LimitAmount must not be native")
+		case tx.LimitAmount.Issuer.Equals(tx.Account):
+			return fmt.Errorf("LimitAmount issuer must not be the Account")
+		}
+	case *AccountSet:
+		return validateAccountSet(tx)
+	case *AccountDelete:
+		if tx.Destination.Equals(tx.Account) {
+			return fmt.Errorf("Destination must not be the Account")
+		}
+	}
+	return nil
+}
+
+func validatePayment(tx *Payment) error {
+	if err := checkAmount("Amount", tx.Amount, true); err != nil {
+		return err
+	}
+	if tx.SendMax != nil {
+		if err := checkAmount("SendMax", *tx.SendMax, true); err != nil {
+			return err
+		}
+	}
+	native := tx.Amount.IsNative() && (tx.SendMax == nil || tx.SendMax.IsNative())
+	switch {
+	case native && tx.SendMax != nil:
+		return fmt.Errorf("SendMax must not be given for a native Payment")
+	case native && tx.Paths != nil:
+		return fmt.Errorf("Paths must not be given for a native Payment")
+	case native && tx.Flags != nil && *tx.Flags&TxPartialPayment != 0:
+		return fmt.Errorf("A native Payment cannot be partial")
+	case tx.Destination.Equals(tx.Account) && tx.Paths == nil && (tx.SendMax == nil || tx.SendMax.Issue().Equals(tx.Amount.Issue())):
+		return fmt.Errorf("Payment to self must convert between currencies")
+	}
+	return nil
+}
+
+func validateOfferCreate(tx *OfferCreate) error {
+	if err := checkAmount("TakerPays", tx.TakerPays, true); err != nil {
+		return err
+	}
+	if err := checkAmount("TakerGets", tx.TakerGets, true); err != nil {
+		return err
+	}
+	switch {
+	case tx.TakerPays.IsNative() && tx.TakerGets.IsNative():
+		return fmt.Errorf("TakerPays and TakerGets cannot both be native")
+	case tx.TakerPays.Issue().Equals(tx.TakerGets.Issue()):
+		return fmt.Errorf("TakerPays and TakerGets must differ")
+	case tx.Flags != nil && *tx.Flags&TxImmediateOrCancel != 0 && *tx.Flags&TxFillOrKill != 0:
+		return fmt.Errorf("ImmediateOrCancel and FillOrKill are exclusive")
+	}
+	return nil
+}
+
+func validateAccountSet(tx *AccountSet) error {
+	for _, flag := range []*AccountSetFlag{tx.SetFlag, tx.ClearFlag} {
+		if flag != nil && !flag.IsValid() {
+			return fmt.Errorf("Unknown AccountSetFlag: %d", uint32(*flag))
+		}
+	}
+	if tx.SetFlag != nil && tx.ClearFlag != nil && *tx.SetFlag == *tx.ClearFlag {
+		return fmt.Errorf("Cannot both set and clear %s", tx.SetFlag)
+	}
+	return nil
+}
+
+// checkAmount verifies that a non-native amount has a currency other
+// than ICC and an issuer, and that it is positive if required
+func checkAmount(name string, a Amount, positive bool) error {
+	switch {
+	case !a.IsNative() && a.Currency.IsNative():
+		return fmt.Errorf("%s must be native to use ICC: %s", name, a)
+	case !a.IsNative() && a.Issuer.IsZero():
+		return fmt.Errorf("%s has no issuer: %s", name, a)
+	case a.IsNegative():
+		return fmt.Errorf("%s must not be negative: %s", name, a)
+	case positive && a.IsZero():
+		return fmt.Errorf("%s must be positive: %s", name, a)
+	}
+	return nil
+}
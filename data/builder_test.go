@@ -0,0 +1,218 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+
+	. "gopkg.in/check.v1"
+)
+
+type BuilderSuite struct{}
+
+var _ = Suite(&BuilderSuite{})
+
+func builderAccount(c *C, address string) Account {
+	account, err := NewAccountFromAddress(address)
+	c.Assert(err, IsNil)
+	return *account
+}
+
+func builderAmount(c *C, s string) Amount {
+	amount, err := NewAmount(s)
+	c.Assert(err, IsNil)
+	return *amount
+}
+
+func (s *BuilderSuite) TestPayment(c *C) {
+	alice := builderAccount(c, "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	bob := builderAccount(c, "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9")
+	fee := builderAmount(c, "10").Value
+
+	tx, err := NewPayment(alice, bob, builderAmount(c, "1000000")).
+		Sequence(12).
+		Fee(*fee).
+		Set("DestinationTag", uint32(7)).
+		Flags(TxCanonicalSignature).
+		Build()
+	c.Assert(err, IsNil)
+	payment := tx.(*Payment)
+	c.Check(payment.Sequence, Equals, uint32(12))
+	c.Check(*payment.DestinationTag, Equals, uint32(7))
+	c.Check(payment.Destination, Equals, bob)
+	c.Check(*payment.Flags, Equals, TxCanonicalSignature)
+
+	// Later calls to the builder leave what it built alone
+	builder := NewPayment(alice, bob, builderAmount(c, "1000000")).Sequence(12).Fee(*fee)
+	first, err := builder.Build()
+	c.Assert(err, IsNil)
+	before, err := AllHashes(first)
+	c.Assert(err, IsNil)
+	second, err := builder.Sequence(13).Flags(TxNoDirectRipple).Build()
+	c.Assert(err, IsNil)
+	c.Check(first.GetBase().Sequence, Equals, uint32(12))
+	c.Check(first.GetBase().Flags, IsNil)
+	c.Check(second.GetBase().Sequence, Equals, uint32(13))
+	after, err := AllHashes(first)
+	c.Assert(err, IsNil)
+	c.Check(after, DeepEquals, before)
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Build()
+	c.Check(err, ErrorMatches, "Payment missing required fields: Fee, Sequence")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Set("TakerPays", builderAmount(c, "1")).Build()
+	c.Check(err, ErrorMatches, "Payment has no field TakerPays")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Set("DestinationTag", "seven").Build()
+	c.Check(err, ErrorMatches, "Cannot set DestinationTag to string")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Sequence(1).Fee(*fee).Flags(TxSetFreeze).Build()
+	c.Check(err, ErrorMatches, "Payment does not accept flags: 00100000")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Sequence(1).Fee(*builderAmount(c, "1/USD/"+bob.String()).Value).Build()
+	c.Check(err, ErrorMatches, "Fee must be a non-negative native amount: .*")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "1000000")).Sequence(1).Fee(*fee).Flags(TxPartialPayment).Build()
+	c.Check(err, ErrorMatches, "A native Payment cannot be partial")
+
+	_, err = NewPayment(alice, bob, builderAmount(c, "0")).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "Amount must be positive: .*")
+
+	_, err = NewPayment(alice, alice, builderAmount(c, "1/USD/"+bob.String())).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "Payment to self must convert between currencies")
+}
+
+func (s *BuilderSuite) TestOfferCreateAndTrustSet(c *C) {
+	alice := builderAccount(c, "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	bob := builderAccount(c, "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9")
+	fee := builderAmount(c, "10").Value
+	usd := builderAmount(c, "100/USD/"+bob.String())
+
+	_, err := NewOfferCreate(alice, usd, builderAmount(c, "1000000")).Sequence(1).Fee(*fee).Flags(TxSell).Build()
+	c.Check(err, IsNil)
+	_, err = NewOfferCreate(alice, builderAmount(c, "1"), builderAmount(c, "1000000")).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "TakerPays and TakerGets cannot both be native")
+	_, err = NewOfferCreate(alice, usd, usd).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "TakerPays and TakerGets must differ")
+	_, err = NewOfferCreate(alice, usd, builderAmount(c, "1")).Sequence(1).Fee(*fee).Flags(TxImmediateOrCancel | TxFillOrKill).Build()
+	c.Check(err, ErrorMatches, "ImmediateOrCancel and FillOrKill are exclusive")
+
+	_, err = NewTrustSet(alice, usd).Sequence(1).Fee(*fee).Flags(TxSetNoRipple).Build()
+	c.Check(err, IsNil)
+	_, err = NewTrustSet(bob, usd).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "LimitAmount issuer must not be the Account")
+	_, err = NewTrustSet(alice, builderAmount(c, "1")).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "LimitAmount must not be native")
+
+	_, err = NewOfferCancel(alice, 5).Sequence(1).Fee(*fee).Build()
+	c.Check(err, IsNil)
+	_, err = NewAccountDelete(alice, alice).Sequence(1).Fee(*fee).Build()
+	c.Check(err, ErrorMatches, "Destination must not be the Account")
+}
+
+func (s *BuilderSuite) TestFormatsMatchTransactions(c *C) {
+	for typ, format := range txFormats {
+		factory := txFactory(typ)
+		c.Assert(factory, NotNil, Commentf("%s", typ))
+		v := reflect.ValueOf(factory()).Elem()
+		for _, f := range []map[string]fieldRequirement{commonTxFormat, format} {
+			for name := range f {
+				c.Check(fieldByName(v, name).IsValid(), Equals, true, Commentf("%s.%s", typ, name))
+			}
+		}
+	}
+}
+
+func (s *BuilderSuite) TestAccountSet(c *C) {
+	alice := builderAccount(c, "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	fee := builderAmount(c, "10").Value
+
+	tx, err := NewAccountSet(alice).Sequence(1).Fee(*fee).SetFlag(AsfDefaultRipple).ClearFlag(AsfRequireDest).Build()
+	c.Assert(err, IsNil)
+	accountSet := tx.(*AccountSet)
+	c.Check(accountSet.ExplainFlags(), DeepEquals, []string{"+DefaultRipple", "-RequireDest"})
+	c.Check(accountSet.AccountRootFlags(LsRequireDestTag|LsRequireAuth), Equals, LsRequireAuth|LsDefaultRipple)
+
+	_, err = NewAccountSet(alice).Sequence(1).Fee(*fee).SetFlag(AsfNoFreeze).ClearFlag(AsfNoFreeze).Build()
+	c.Check(err, ErrorMatches, "Cannot both set and clear NoFreeze")
+	_, err = NewAccountSet(alice).Sequence(1).Fee(*fee).SetFlag(AccountSetFlag(99)).Build()
+	c.Check(err, ErrorMatches, "Unknown AccountSetFlag: 99")
+	_, err = NewAccountSet(alice).Sequence(1).Fee(*fee).Flags(TransactionFlag(AsfRequireDest)).Build()
+	c.Check(err, ErrorMatches, "AccountSet does not accept flags: 00000001")
+
+	// NoFreeze is permanent and prevents GlobalFreeze being cleared
+	frozen := LsNoFreeze | LsGlobalFreeze
+	clear := AsfNoFreeze
+	c.Check((&AccountSet{ClearFlag: &clear}).AccountRootFlags(frozen), Equals, frozen)
+	clear = AsfGlobalFreeze
+	c.Check((&AccountSet{ClearFlag: &clear}).AccountRootFlags(frozen), Equals, frozen)
+	c.Check((&AccountSet{ClearFlag: &clear}).AccountRootFlags(LsGlobalFreeze), Equals, LedgerEntryFlag(0))
+
+	// The older transaction flags still apply
+	flags := TxRequireDestTag | TxAllowXRP
+	c.Check((&AccountSet{TxBase: TxBase{Flags: &flags}}).AccountRootFlags(LsDisallowXRP), Equals, LsRequireDestTag)
+}
+
+func (s *BuilderSuite) TestXAddressDestination(c *C) {
+	alice := builderAccount(c, "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	fee := builderAmount(c, "10").Value
+
+	tx, err := NewPaymentTo(alice, "XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC", builderAmount(c, "1")).Sequence(1).Fee(*fee).Build()
+	c.Assert(err, IsNil)
+	payment := tx.(*Payment)
+	c.Check(payment.Destination.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(*payment.DestinationTag, Equals, uint32(1))
+
+	tx, err = NewPaymentTo(alice, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf", builderAmount(c, "1")).Sequence(1).Fee(*fee).Build()
+	c.Assert(err, IsNil)
+	c.Check(tx.(*Payment).DestinationTag, IsNil)
+
+	_, err = NewPaymentTo(alice, "XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDD", builderAmount(c, "1")).Build()
+	c.Check(err, ErrorMatches, "Bad Base58 checksum.*")
+
+	// Test network addresses must be asked for
+	_, err = NewPaymentTo(alice, "TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw", builderAmount(c, "1")).Build()
+	c.Check(err, ErrorMatches, "X-address is for the test network: TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw")
+	tx, err = NewBuilder(PAYMENT, alice).TestnetDestination("TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw").Set("Amount", builderAmount(c, "1")).Sequence(1).Fee(*fee).Build()
+	c.Assert(err, IsNil)
+	c.Check(tx.(*Payment).Destination.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(*tx.(*Payment).DestinationTag, Equals, uint32(1))
+}
+
+func (s *BuilderSuite) TestTaggedAccountJSON(c *C) {
+	var tagged TaggedAccount
+	c.Assert(json.Unmarshal([]byte(`"TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw"`), &tagged), IsNil)
+	c.Check(tagged.Account.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(*tagged.Tag, Equals, uint32(1))
+	c.Check(tagged.Test, Equals, true)
+	b, err := json.Marshal(tagged)
+	c.Assert(err, IsNil)
+	c.Check(string(b), Equals, `"TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw"`)
+
+	var account Account
+	c.Assert(json.Unmarshal([]byte(`"XVLhHMPHU98es4dbozjVtdWzViDjtV5fdx1mHp98tDMoQXb"`), &account), IsNil)
+	c.Check(account.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(account.XAddress(nil, false), Equals, "XVLhHMPHU98es4dbozjVtdWzViDjtV5fdx1mHp98tDMoQXb")
+	// The tag would be lost
+	err = json.Unmarshal([]byte(`"XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC"`), &account)
+	c.Check(err, ErrorMatches, "X-address has a tag: .*")
+	err = json.Unmarshal([]byte(`"TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw"`), &account)
+	c.Check(err, ErrorMatches, "X-address is for the test network: .*")
+}
+
+func (s *BuilderSuite) TestXAddressDestinationJSON(c *C) {
+	var payment Payment
+	c.Assert(json.Unmarshal([]byte(`{"TransactionType":"Payment","Destination":"XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC","Amount":"1"}`), &payment), IsNil)
+	c.Check(payment.Destination.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(*payment.DestinationTag, Equals, uint32(1))
+	c.Check(payment.Amount.String(), Equals, "0.000001/ICC")
+
+	var accountDelete AccountDelete
+	c.Assert(json.Unmarshal([]byte(`{"Destination":"XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC","DestinationTag":1}`), &accountDelete), IsNil)
+	c.Check(accountDelete.Destination.String(), Equals, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+	c.Check(*accountDelete.DestinationTag, Equals, uint32(1))
+
+	err := json.Unmarshal([]byte(`{"Destination":"XVLhHMPHU98es4dbozjVtdWzViDjtV8xvjGQTYPrAx6gwDC","DestinationTag":2}`), &payment)
+	c.Check(err, ErrorMatches, "X-address tag 1 conflicts with DestinationTag 2")
+	err = json.Unmarshal([]byte(`{"Destination":"TVE26TYGhfLC7tQDno7G8dGtxSkYQnSz1uDrmDdPYXzSpyw"}`), &payment)
+	c.Check(err, ErrorMatches, "X-address is for the test network: .*")
+}
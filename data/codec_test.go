@@ -1,8 +1,14 @@
 package data
 
 import (
+	"bytes"
+	"encoding/hex"
 	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
 
+	"github.com/wangch/ripple/crypto"
 	internal "github.com/wangch/ripple/testing"
 	. "gopkg.in/check.v1"
 )
@@ -64,6 +70,55 @@ func (s *CodecSuite) TestParseNodes(c *C) {
 	}
 }
 
+// Account state leaves from ledger 99943, as the hash their parent holds
+// and the stored node
+var stateLeaves = []string{
+	"C33503A9095C6C96705873B1718BBBB58C9BAC0896CFB5AD82C7BCB4C4B46C3B:0000000000000000034D4C4E001100612200000000240000000A2500017D0A2B3BE715402D00000000413A3C36EEB25EDD249CE94474035CB006551913D115FF45B60E6D0A9A241994D8A3F4A6426B303F0DDAE5A0CDA758C849D262400000003E2AEA66770D776565786368616E67652E636F81147469B8AA28E3EB31ECFFE48C1E66D1BB185DB789006E0B1413DC1D5A8076190562F32B015894CA550AC8A335115F2BF8C453759F",
+	"516E55AE9D90AACE555194EBDF218D9D160BFB452CB0316C67E61C5EB5894558:0000000000000000034D4C4E0011007222000300002500011B3D37000000000000000038000000000000000055921FAE2F6F95DC354122EEFFB86BF894A947BC0E8FCB3B21CB705762B7A5CB7962D4871AFD498D00000000000000000000000000005553440000000000000000000000000000000000000000000000000166D4C38D7EA4C68000000000000000000000000000555344000000000012DC0654E3190F66CC994EF9E214503305B979AD67D4871AFD498D0000000000000000000000000000555344000000000054EE3CE2AC4E9F5524BBCCE0C77F7DEF1CFC46C9017AF787B464E572EE0CEA0777D0D7CE494BC83AEF1F9E97301BF686DFD0B213",
+}
+
+func (s *CodecSuite) TestLedgerEntryEncoding(c *C) {
+	for _, leaf := range stateLeaves {
+		parts := strings.Split(leaf, ":")
+		hash, err := NewHash256(parts[0])
+		c.Assert(err, IsNil)
+		value, err := hex.DecodeString(parts[1])
+		c.Assert(err, IsNil)
+		n, err := ReadPrefix(bytes.NewReader(value), *hash)
+		c.Assert(err, IsNil)
+		le := n.(LedgerEntry)
+		msg := Commentf("%s", le.GetLedgerEntryType())
+		// The index is stored after the fields
+		index, err := NewHash256(parts[1][len(parts[1])-64:])
+		c.Assert(err, IsNil)
+
+		// LedgerEntryType and the PreviousTxn fields are encoded, the
+		// index follows the fields rather than being one of them
+		_, encoded, err := Node(n)
+		c.Assert(err, IsNil, msg)
+		c.Check(string(b2h(encoded)), Equals, parts[1], msg)
+		leafHash, err := LeafHash(le, *index)
+		c.Assert(err, IsNil, msg)
+		c.Check(leafHash, Equals, *hash, msg)
+
+		// An entry read from JSON, where the index is just another
+		// field, hashes the same
+		b, err := json.Marshal(le)
+		c.Assert(err, IsNil, msg)
+		var fields map[string]interface{}
+		c.Assert(json.Unmarshal(b, &fields), IsNil, msg)
+		fields["index"] = index.String()
+		b, err = json.Marshal(fields)
+		c.Assert(err, IsNil, msg)
+		copied := ledgerEntryFactory(le.GetLedgerEntryType())()
+		c.Assert(json.Unmarshal(b, copied), IsNil, msg)
+		c.Assert(copied.GetLedgerIndex(), NotNil, msg)
+		leafHash, err = LeafHash(copied, *copied.GetLedgerIndex())
+		c.Assert(err, IsNil, msg)
+		c.Check(leafHash, Equals, *hash, msg)
+	}
+}
+
 func (s *CodecSuite) TestBadNodes(c *C) {
 	for _, test := range internal.BadNodes {
 		nodeid, err := NewHash256(test.NodeId())
@@ -93,3 +148,334 @@ func (s *CodecSuite) TestParseMetaData(c *C) {
 		}
 	}
 }
+
+func (s *CodecSuite) TestRoundTripNewTypes(c *C) {
+	tag := uint32(42)
+	var owner, authorized Account
+	owner[19], authorized[19] = 1, 2
+	txs := []Transaction{
+		&AccountDelete{
+			TxBase:         TxBase{TransactionType: ACCOUNT_DELETE, Account: owner, Sequence: 5, Fee: *zeroNative.Clone()},
+			Destination:    authorized,
+			DestinationTag: &tag,
+		},
+		&DepositPreauth{
+			TxBase:    TxBase{TransactionType: SET_DEPOSIT_PREAUTH, Account: owner, Sequence: 6, Fee: *zeroNative.Clone()},
+			Authorize: &authorized,
+		},
+	}
+	for _, tx := range txs {
+		_, raw, err := Raw(tx)
+		c.Assert(err, IsNil)
+		decoded, err := ReadTransaction(bytes.NewReader(raw))
+		c.Assert(err, IsNil)
+		c.Assert(decoded.GetTransactionType(), Equals, tx.GetTransactionType())
+		_, again, err := Raw(decoded)
+		c.Assert(err, IsNil)
+		c.Assert(again, DeepEquals, raw)
+	}
+	preauth := &Preauthorization{
+		leBase:    leBase{LedgerEntryType: DEPOSIT_PREAUTH},
+		Account:   &owner,
+		Authorize: &authorized,
+	}
+	index, err := LedgerIndex(preauth)
+	c.Assert(err, IsNil)
+	expected, err := GetDepositPreauthIndex(owner, authorized)
+	c.Assert(err, IsNil)
+	c.Assert(*index, Equals, *expected)
+}
+
+func (s *CodecSuite) TestNFToken(c *C) {
+	var issuer Account
+	issuer[0], issuer[19] = 0x95, 0xE2
+	fee := uint16(314)
+	uri := VariableLength("ipfs://token")
+	txs := []Transaction{
+		&NFTokenMint{
+			TxBase:       TxBase{TransactionType: NFTOKEN_MINT, Account: issuer, Sequence: 7, Fee: *zeroNative.Clone()},
+			NFTokenTaxon: 1337,
+			TransferFee:  &fee,
+			URI:          &uri,
+		},
+		&NFTokenCancelOffer{
+			TxBase:        TxBase{TransactionType: NFTOKEN_CANCEL_OFFER, Account: issuer, Sequence: 8, Fee: *zeroNative.Clone()},
+			NFTokenOffers: Vector256{Hash256{1}, Hash256{2}},
+		},
+	}
+	for _, tx := range txs {
+		_, raw, err := Raw(tx)
+		c.Assert(err, IsNil)
+		decoded, err := ReadTransaction(bytes.NewReader(raw))
+		c.Assert(err, IsNil)
+		c.Assert(decoded, DeepEquals, tx)
+	}
+
+	var id Hash256
+	copy(id[:], []byte{0x00, 0x0B, 0x01, 0x3A})
+	copy(id[4:24], issuer[:])
+	copy(id[24:], []byte{0xB4, 0x48, 0x89, 0x39, 0x00, 0x00, 0x0D, 0x65})
+	info := NewNFTokenInfo(id)
+	c.Check(info.Flags, Equals, uint16(11))
+	c.Check(info.TransferFee, Equals, uint16(314))
+	c.Check(info.Issuer, Equals, issuer)
+	c.Check(info.Taxon, Equals, uint32(1337))
+	c.Check(info.Serial, Equals, uint32(3429))
+	c.Check(info.Burnable(), Equals, true)
+	c.Check(info.Transferable(), Equals, true)
+	c.Check(info.TrustLine(), Equals, false)
+}
+
+const testDefinitions = `{
+	"TYPES": {"UInt32": 2, "Blob": 7, "Transaction": 10001},
+	"LEDGER_ENTRY_TYPES": {"FutureEntry": 200},
+	"FIELDS": [
+		["Generic", {"nth": 0, "isVLEncoded": false, "isSerialized": false, "isSigningField": false, "type": "Transaction"}],
+		["FutureCount", {"nth": 200, "isVLEncoded": false, "isSerialized": true, "isSigningField": true, "type": "UInt32"}],
+		["FutureBlob", {"nth": 200, "isVLEncoded": true, "isSerialized": true, "isSigningField": true, "type": "Blob"}]
+	],
+	"TRANSACTION_RESULTS": {"tecFUTURE": 240},
+	"TRANSACTION_TYPES": {"FutureTransaction": 200}
+}`
+
+// newSelfDelete returns a minimal AccountDelete of account to itself
+func newSelfDelete(account Account) *AccountDelete {
+	return &AccountDelete{
+		TxBase:      TxBase{TransactionType: ACCOUNT_DELETE, Account: account, Sequence: 9, Fee: *zeroNative.Clone()},
+		Destination: account,
+	}
+}
+
+// snapshotTables copies each of the maps pointed to by tables and
+// returns a function which puts the copies back
+func snapshotTables(tables ...interface{}) func() {
+	copies := make([]reflect.Value, len(tables))
+	for i, table := range tables {
+		m := reflect.ValueOf(table).Elem()
+		copies[i] = reflect.MakeMap(m.Type())
+		for _, key := range m.MapKeys() {
+			copies[i].SetMapIndex(key, m.MapIndex(key))
+		}
+	}
+	return func() {
+		for i, table := range tables {
+			reflect.ValueOf(table).Elem().Set(copies[i])
+		}
+		resetPlans()
+	}
+}
+
+func (s *CodecSuite) TestDefinitions(c *C) {
+	// Leave the codec tables as the other tests expect them
+	defer snapshotTables(
+		&encodings, &reverseEncodings, &signingFields, &definedFields,
+		&variableTypes, &typeWidths, &txTypes, &definedTxNames,
+		&ledgerEntryTypes, &definedLedgerEntryNames, &resultNames, &reverseResults,
+	)()
+	tx := newSelfDelete(Account{19: 1})
+	_, raw, err := Raw(tx)
+	c.Assert(err, IsNil)
+	// Fields unknown to the struct are skipped
+	extended := append(append([]byte{}, raw...), 0x20, 200, 0, 0, 0, 1, 0x70, 200, 2, 0xAB, 0xCD)
+	decoded, err := ReadTransaction(bytes.NewReader(extended))
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, Transaction(tx))
+
+	c.Assert(LoadDefinitions(bytes.NewReader([]byte(`{}`))), NotNil)
+	c.Assert(LoadDefinitions(bytes.NewReader([]byte(testDefinitions))), IsNil)
+	c.Check(encodings[enc{ST_UINT32, 200}], Equals, "FutureCount")
+	c.Check(reverseEncodings["FutureBlob"], Equals, enc{ST_VL, 200})
+	c.Check(TransactionType(200).String(), Equals, "FutureTransaction")
+	c.Check(LedgerEntryType(200).String(), Equals, "FutureEntry")
+	c.Check(TransactionResult(240).String(), Equals, "tecFUTURE")
+	c.Check(PAYMENT.String(), Equals, "Payment")
+	decoded, err = ReadTransaction(bytes.NewReader(extended))
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, Transaction(tx))
+	_, err = ReadTransaction(bytes.NewReader([]byte{0x12, 0, 200}))
+	c.Assert(err, NotNil)
+}
+
+func (s *CodecSuite) TestSTObject(c *C) {
+	for _, test := range internal.Transactions {
+		raw, err := hex.DecodeString(test.Encoded)
+		c.Assert(err, IsNil)
+		obj, err := DecodeSTObject(raw)
+		c.Assert(err, IsNil, Commentf(test.Description))
+		out, err := json.Marshal(obj)
+		c.Assert(err, IsNil)
+		var parsed map[string]interface{}
+		c.Assert(json.Unmarshal(out, &parsed), IsNil)
+		c.Assert(parsed, DeepEquals, obj)
+		encoded, err := EncodeSTObjectHex(parsed)
+		c.Assert(err, IsNil)
+		c.Assert(encoded, Equals, test.Encoded, Commentf("%s\n%s", test.Description, out))
+	}
+	_, err := EncodeSTObject(map[string]interface{}{"NoSuchField": 1})
+	c.Assert(err, NotNil)
+	_, err = EncodeSTObject(map[string]interface{}{"": 1})
+	c.Assert(err, ErrorMatches, "Unknown field: ")
+	_, err = DecodeSTObject([]byte{0x12, 0x00})
+	c.Assert(err, NotNil)
+}
+
+func (s *CodecSuite) TestCheckCanonical(c *C) {
+	for _, test := range internal.Transactions {
+		raw, err := hex.DecodeString(test.Encoded)
+		c.Assert(err, IsNil)
+		c.Assert(CheckCanonical(raw), IsNil, Commentf(test.Description))
+		_, err = ReadTransactionStrict(raw)
+		c.Assert(err, IsNil, Commentf(test.Description))
+	}
+	tx := newSelfDelete(Account{19: 1})
+	_, raw, err := Raw(tx)
+	c.Assert(err, IsNil)
+	c.Assert(CheckCanonical(raw), IsNil)
+	for _, t := range []struct {
+		blob   []byte
+		offset int
+		path   string
+	}{
+		{append(raw[:len(raw):len(raw)], 0xE1), len(raw), ""},
+		{append(raw[:len(raw):len(raw)], 0xD1), len(raw), ""},
+		{[]byte{0x24, 0, 0, 0, 1, 0x24, 0, 0, 0, 2}, 5, "Sequence"},
+		{[]byte{0x24, 0, 0, 0, 1, 0x22, 0, 0, 0, 2}, 5, "Flags"},
+		{[]byte{0x68, 0, 0, 0, 0, 0, 0, 0, 0}, 1, "Fee"},
+		{append([]byte{0x61, 0xD4, 0x80, 0, 0, 0, 0, 0, 1}, make([]byte, 40)...), 1, "Amount"},
+		{[]byte{0xE0, 0x0A, 0x7C, 0x00, 0xE1}, 0, ""},
+		{[]byte{0x81, 0x02, 0x00, 0x00}, 1, "Account"},
+	} {
+		err := CheckCanonical(t.blob)
+		c.Assert(err, NotNil, Commentf("%X", t.blob))
+		c.Check(err.(*DecodeError).Offset, Equals, t.offset, Commentf("%s", err))
+		c.Check(err.(*DecodeError).Path, Equals, t.path, Commentf("%s", err))
+	}
+	// Negative native amounts are representable, if not valid in a transaction
+	c.Check(CheckCanonical([]byte{0x68, 0, 0, 0, 0, 0, 0, 0, 1}), IsNil)
+}
+
+func (s *CodecSuite) TestSignFullyCanonical(c *C) {
+	key, err := crypto.NewECDSAKey([]byte("canonical signature test seed"))
+	c.Assert(err, IsNil)
+	var sequence uint32
+	var account Account
+	copy(account[:], key.Id(&sequence))
+	tx := newSelfDelete(account)
+	c.Assert(Sign(tx, key, &sequence), IsNil)
+	c.Assert(tx.Flags, NotNil)
+	c.Check(*tx.Flags&TxCanonicalSignature, Equals, TxCanonicalSignature)
+	ok, err := CheckSignature(tx)
+	c.Check(ok, Equals, true)
+	c.Check(err, IsNil)
+	ok, err = CheckCanonicalSignature(tx, crypto.FullyCanonical)
+	c.Check(ok, Equals, true)
+	c.Check(err, IsNil)
+
+	// A malformed signature fails even the lowest canonicality, flagged or not
+	*tx.TxnSignature = VariableLength{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x81}
+	*tx.Flags = 0
+	_, err = CheckCanonicalSignature(tx, crypto.Canonical)
+	c.Check(err, NotNil)
+	*tx.Flags = TxCanonicalSignature
+	ok, err = CheckSignature(tx)
+	c.Check(ok, Equals, false)
+	c.Check(err, NotNil)
+}
+
+func (s *CodecSuite) TestAllHashes(c *C) {
+	for _, test := range internal.Transactions {
+		tx, err := ReadTransaction(test.Reader())
+		c.Assert(err, IsNil)
+		nodeid, raw, err := Raw(tx)
+		c.Assert(err, IsNil)
+		signingHash, signingBytes, err := SigningHash(tx)
+		c.Assert(err, IsNil)
+		hashes, err := AllHashes(tx)
+		c.Assert(err, IsNil)
+		c.Check(hashes.NodeId, Equals, nodeid)
+		c.Check(hashes.Raw, DeepEquals, raw)
+		c.Check(hashes.SigningHash, Equals, signingHash)
+		c.Check(hashes.SigningBytes, DeepEquals, signingBytes)
+		c.Check(hashes.SuppressionId, Equals, nodeid)
+		// Nothing is kept to go stale when the transaction changes
+		tx.GetBase().Sequence++
+		changed, err := AllHashes(tx)
+		c.Assert(err, IsNil)
+		c.Check(changed.NodeId, Not(Equals), hashes.NodeId)
+	}
+	for _, test := range internal.Validations {
+		v, err := ReadValidation(test.Reader())
+		c.Assert(err, IsNil)
+		nodeid, raw, err := Raw(v)
+		c.Assert(err, IsNil)
+		signingHash, _, err := SigningHash(v)
+		c.Assert(err, IsNil)
+		hashes, err := AllHashes(v)
+		c.Assert(err, IsNil)
+		c.Check(hashes.NodeId, Equals, nodeid)
+		c.Check(hashes.Raw, DeepEquals, raw)
+		c.Check(hashes.SigningHash, Equals, signingHash)
+	}
+
+	// A transaction with metadata encodes its transaction once
+	txm, err := readTransactionFixture("transaction_payment_with_rippling.json")
+	c.Assert(err, IsNil)
+	hashes, err := AllHashes(txm)
+	c.Assert(err, IsNil)
+	nodeid, raw, err := Raw(txm)
+	c.Assert(err, IsNil)
+	c.Check(hashes.NodeId, Equals, nodeid)
+	c.Check(hashes.Raw, DeepEquals, raw)
+	c.Check(hashes.SuppressionId, Equals, *txm.GetHash())
+	tx, err := AllHashes(txm.Transaction)
+	c.Assert(err, IsNil)
+	c.Check(hashes.SigningHash, Equals, tx.SigningHash)
+
+	var meta bytes.Buffer
+	c.Assert(encode(&meta, &txm.MetaData, false), IsNil)
+	read, err := ReadTransactionAndMetadata(bytes.NewReader(tx.Raw), bytes.NewReader(meta.Bytes()), *txm.GetHash(), 1)
+	c.Assert(err, IsNil)
+	c.Check(read.Id, Equals, nodeid)
+	_, err = ReadTransactionAndMetadata(bytes.NewReader(tx.Raw), bytes.NewReader(meta.Bytes()), zero256, 1)
+	c.Check(err, ErrorMatches, "Transaction hash mismatch: .*")
+}
+
+func readTransactions(b *testing.B) []Transaction {
+	var txs []Transaction
+	for _, test := range internal.Transactions {
+		tx, err := ReadTransaction(test.Reader())
+		if err != nil {
+			b.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+func BenchmarkSeparateHashes(b *testing.B) {
+	txs := readTransactions(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tx := range txs {
+			Raw(tx)
+			NodeId(tx)
+			SigningHash(tx)
+		}
+	}
+}
+
+func BenchmarkAllHashes(b *testing.B) {
+	txs := readTransactions(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, tx := range txs {
+			AllHashes(tx)
+		}
+	}
+}
+
+func BenchmarkReadTransactions(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		readTransactions(b)
+	}
+}
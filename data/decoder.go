@@ -71,7 +71,11 @@ func ReadTransaction(r Reader) (Transaction, error) {
 	if err != nil {
 		return nil, err
 	}
-	tx := TxFactory[txType]()
+	factory := txFactory(TransactionType(txType))
+	if factory == nil {
+		return nil, fmt.Errorf("Unsupported TransactionType: %d", txType)
+	}
+	tx := factory()
 	v := reflect.ValueOf(tx)
 	if err := readObject(r, &v); err != nil {
 		return nil, err
@@ -94,10 +98,15 @@ func ReadTransactionAndMetadata(tx, meta Reader, hash Hash256, ledger uint32) (*
 	if err := readObject(meta, &m); err != nil {
 		return nil, err
 	}
-	*txm.GetHash() = hash
-	if txm.Id, err = NodeId(txm); err != nil {
+	hashes, err := AllHashes(txm)
+	if err != nil {
 		return nil, err
 	}
+	if hashes.SuppressionId != hash {
+		return nil, fmt.Errorf("Transaction hash mismatch: %s != %s", hashes.SuppressionId, hash)
+	}
+	*txm.GetHash() = hash
+	txm.Id = hashes.NodeId
 	return txm, nil
 }
 
@@ -160,7 +169,11 @@ func ReadLedgerEntry(r Reader, nodeId Hash256) (LedgerEntry, error) {
 	if err != nil {
 		return nil, err
 	}
-	le := LedgerEntryFactory[leType]()
+	factory := ledgerEntryFactory(LedgerEntryType(leType))
+	if factory == nil {
+		return nil, fmt.Errorf("Unsupported LedgerEntryType: %d", leType)
+	}
+	le := factory()
 	v := reflect.ValueOf(le)
 	// LedgerEntries have 32 bytes of index suffixed
 	// but don't have a variable bytes indicator
@@ -229,6 +242,13 @@ func readObject(r Reader, v *reflect.Value) error {
 				return errorEndOfArray
 			}
 			array := getField(v, enc)
+			if !array.IsValid() && skippable(enc) {
+				// Unknown to this struct, most likely a newer field
+				if err := skipField(r, enc.typ); err != nil {
+					return err
+				}
+				continue
+			}
 		loop:
 			for {
 				child := reflect.New(array.Type().Elem()).Elem()
@@ -247,10 +267,13 @@ func readObject(r Reader, v *reflect.Value) error {
 			case "EndOfObject":
 				return errorEndOfObject
 			case "PreviousFields", "NewFields", "FinalFields":
-				leType := LedgerEntryType(v.Elem().FieldByName("LedgerEntryType").Uint())
-				le := LedgerEntryFactory[leType]()
-				fields := reflect.ValueOf(le)
-				v.Elem().FieldByName(name).Set(fields)
+				leType := LedgerEntryType(fieldByName(v.Elem(), "LedgerEntryType").Uint())
+				factory := ledgerEntryFactory(leType)
+				if factory == nil {
+					return fmt.Errorf("Unsupported LedgerEntryType: %d", leType)
+				}
+				fields := reflect.ValueOf(factory())
+				fieldByName(v.Elem(), name).Set(fields)
 				if err := readObject(r, &fields); err != nil && err != errorEndOfObject {
 					return err
 				}
@@ -265,7 +288,7 @@ func readObject(r Reader, v *reflect.Value) error {
 				n := reflect.ValueOf(&node)
 				var effect NodeEffect
 				e := reflect.ValueOf(&effect)
-				e.Elem().FieldByName(name).Set(n)
+				fieldByName(e.Elem(), name).Set(n)
 				v.Set(e.Elem())
 				return readObject(r, &n)
 			case "Memo":
@@ -275,6 +298,13 @@ func readObject(r Reader, v *reflect.Value) error {
 				err := readObject(r, &inner)
 				v.Set(m.Elem())
 				return err
+			case "NFToken":
+				var token NFToken
+				t := reflect.ValueOf(&token)
+				inner := reflect.ValueOf(&token.NFToken)
+				err := readObject(r, &inner)
+				v.Set(t.Elem())
+				return err
 			default:
 				return fmt.Errorf("Unexpected object: %s for field: %s", v.Type(), name)
 			}
@@ -283,6 +313,13 @@ func readObject(r Reader, v *reflect.Value) error {
 				return fmt.Errorf("Unexpected object: %s for field: %s", v.Type(), name)
 			}
 			field := getField(v, enc)
+			if !field.IsValid() && skippable(enc) {
+				// Unknown to this struct, most likely a newer field
+				if err := skipField(r, enc.typ); err != nil {
+					return err
+				}
+				continue
+			}
 			if !field.CanAddr() {
 				return fmt.Errorf("Missing field: %s", name)
 			}
@@ -303,7 +340,7 @@ func readObject(r Reader, v *reflect.Value) error {
 
 func getField(v *reflect.Value, e *enc) *reflect.Value {
 	name := encodings[*e]
-	field := v.Elem().FieldByName(name)
+	field := fieldByName(v.Elem(), name)
 	if field.Kind() == reflect.Ptr {
 		field.Set(reflect.New(field.Type().Elem()))
 		field = field.Elem()
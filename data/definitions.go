@@ -0,0 +1,208 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Definitions mirrors the definitions.json file published with rippled
+// and the ripple-binary-codec. Loading one with LoadDefinitions extends
+// the built-in field, type and result tables, so that fields added by
+// later protocol amendments can be decoded without a new release.
+type Definitions struct {
+	Types              map[string]int    `json:"TYPES"`
+	LedgerEntryTypes   map[string]int    `json:"LEDGER_ENTRY_TYPES"`
+	Fields             []FieldDefinition `json:"FIELDS"`
+	TransactionResults map[string]int    `json:"TRANSACTION_RESULTS"`
+	TransactionTypes   map[string]int    `json:"TRANSACTION_TYPES"`
+}
+
+type FieldDefinition struct {
+	Name           string
+	Nth            int    `json:"nth"`
+	Type           string `json:"type"`
+	IsVLEncoded    bool   `json:"isVLEncoded"`
+	IsSerialized   bool   `json:"isSerialized"`
+	IsSigningField bool   `json:"isSigningField"`
+}
+
+// UnmarshalJSON reads the ["Name", {...}] pairs used in the FIELDS array
+func (f *FieldDefinition) UnmarshalJSON(b []byte) error {
+	var pair []json.RawMessage
+	if err := json.Unmarshal(b, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 {
+		return fmt.Errorf("Bad field definition: %s", string(b))
+	}
+	if err := json.Unmarshal(pair[0], &f.Name); err != nil {
+		return err
+	}
+	type info FieldDefinition
+	return json.Unmarshal(pair[1], (*info)(f))
+}
+
+// Widths of the fixed size types which the codec can skip
+// when a field has no counterpart in the target struct
+var fixedWidths = map[string]int{
+	"UInt8":    1,
+	"UInt16":   2,
+	"UInt32":   4,
+	"UInt64":   8,
+	"Hash128":  16,
+	"Hash160":  20,
+	"Hash256":  32,
+	"UInt96":   12,
+	"UInt192":  24,
+	"UInt384":  48,
+	"UInt512":  64,
+	"Int32":    4,
+	"Int64":    8,
+	"Currency": 20,
+	"Number":   12,
+}
+
+var typeWidths = map[uint8]int{
+	ST_UINT8:   1,
+	ST_UINT16:  2,
+	ST_UINT32:  4,
+	ST_UINT64:  8,
+	ST_HASH128: 16,
+	ST_HASH160: 20,
+	ST_HASH256: 32,
+}
+
+var variableTypes = map[uint8]bool{
+	ST_VL:        true,
+	ST_ACCOUNT:   true,
+	ST_VECTOR256: true,
+}
+
+var (
+	definedFields           = make(map[enc]bool)
+	definedTxNames          = make(map[TransactionType]string)
+	definedLedgerEntryNames = make(map[LedgerEntryType]string)
+)
+
+// ReadDefinitions parses a definitions.json file without applying it
+func ReadDefinitions(r io.Reader) (*Definitions, error) {
+	var defs Definitions
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, err
+	}
+	if len(defs.Types) == 0 || len(defs.Fields) == 0 {
+		return nil, fmt.Errorf("Definitions missing TYPES or FIELDS")
+	}
+	return &defs, nil
+}
+
+// LoadDefinitions reads a definitions.json file and merges it into
+// the codec tables. Entries in the file take precedence over the
+// built-in ones, which remain as a fall back for anything the file
+// does not mention. It should be called before any encoding or
+// decoding takes place and is not safe for concurrent use.
+func LoadDefinitions(r io.Reader) error {
+	defs, err := ReadDefinitions(r)
+	if err != nil {
+		return err
+	}
+	return defs.Apply()
+}
+
+// Apply merges the definitions into the codec tables
+func (defs *Definitions) Apply() error {
+	for _, f := range defs.Fields {
+		code, ok := defs.Types[f.Type]
+		if !ok {
+			return fmt.Errorf("Unknown type: %s for field: %s", f.Type, f.Name)
+		}
+		// Skip the pseudo fields and types which never reach the wire
+		if !f.IsSerialized || code <= 0 || code > 255 || f.Nth <= 0 || f.Nth > 255 {
+			continue
+		}
+		e := enc{uint8(code), uint8(f.Nth)}
+		if _, ok := encodings[e]; !ok {
+			definedFields[e] = true
+		}
+		encodings[e] = f.Name
+		reverseEncodings[f.Name] = e
+		if !f.IsSigningField {
+			signingFields[e] = struct{}{}
+		}
+		if f.IsVLEncoded {
+			variableTypes[e.typ] = true
+		} else if width, ok := fixedWidths[f.Type]; ok {
+			typeWidths[e.typ] = width
+		}
+	}
+	for name, code := range defs.TransactionTypes {
+		if code < 0 || code > 0xFFFF {
+			continue
+		}
+		txTypes[name] = TransactionType(code)
+		definedTxNames[TransactionType(code)] = name
+	}
+	for name, code := range defs.LedgerEntryTypes {
+		if code < 0 || code > 0xFFFF {
+			continue
+		}
+		ledgerEntryTypes[name] = LedgerEntryType(code)
+		definedLedgerEntryNames[LedgerEntryType(code)] = name
+	}
+	for name, code := range defs.TransactionResults {
+		result := TransactionResult(code)
+		if _, ok := resultNames[result]; !ok {
+			resultNames[result] = struct {
+				Token string
+				Human string
+			}{name, ""}
+		}
+		reverseResults[name] = result
+	}
+	resetPlans()
+	return nil
+}
+
+// skippable reports whether a field missing from the struct being
+// decoded is newer than the built-in tables, rather than misplaced
+func skippable(e *enc) bool {
+	_, known := encodings[*e]
+	return !known || definedFields[*e]
+}
+
+// skipField discards the value of a field with no counterpart in the
+// struct being decoded
+func skipField(r Reader, typ uint8) error {
+	switch {
+	case variableTypes[typ]:
+		length, err := readVariableLength(r)
+		if err != nil {
+			return err
+		}
+		return unmarshalSlice(make([]byte, length), r, "Skip")
+	case typ == ST_AMOUNT:
+		var amount Amount
+		return amount.Unmarshal(r)
+	case typ == ST_PATHSET:
+		var path PathSet
+		return path.Unmarshal(r)
+	case typ == ST_OBJECT, typ == ST_ARRAY:
+		for {
+			e, err := readEncoding(r)
+			if err != nil {
+				return err
+			}
+			if e.typ == typ && e.field == 1 {
+				return nil
+			}
+			if err := skipField(r, e.typ); err != nil {
+				return err
+			}
+		}
+	}
+	if width, ok := typeWidths[typ]; ok {
+		return unmarshalSlice(make([]byte, width), r, "Skip")
+	}
+	return fmt.Errorf("Cannot skip field of unknown type: %d", typ)
+}
@@ -0,0 +1,74 @@
+package data
+
+import "fmt"
+
+// LedgerEntryGetter looks up ledger entries by their index, such as
+// those listed in a Directory
+type LedgerEntryGetter interface {
+	GetLedgerEntry(index Hash256) (LedgerEntry, error)
+}
+
+// DirectoryFunc is called with each entry of a directory and its index
+type DirectoryFunc func(index Hash256, le LedgerEntry) error
+
+// WalkDirectory calls f with each entry listed in the directory whose
+// first page is root, following the pages in order. Both owner and
+// book directories can be walked, the latter one quality at a time.
+func WalkDirectory(source LedgerEntryGetter, root Hash256, f DirectoryFunc) error {
+	var page *NodeIndex
+	seen := make(map[NodeIndex]bool)
+	for {
+		index, err := GetDirectoryNodeIndex(root, page)
+		if err != nil {
+			return err
+		}
+		le, err := source.GetLedgerEntry(*index)
+		if err != nil {
+			return err
+		}
+		dir, ok := le.(*Directory)
+		if !ok {
+			return fmt.Errorf("Not a directory: %s is %s", index, le.GetLedgerEntryType())
+		}
+		if dir.Indexes != nil {
+			for _, entry := range *dir.Indexes {
+				le, err := source.GetLedgerEntry(entry)
+				if err != nil {
+					return err
+				}
+				if err := f(entry, le); err != nil {
+					return err
+				}
+			}
+		}
+		// The last page has no next page or a next page of zero
+		if dir.IndexNext == nil || *dir.IndexNext == 0 {
+			return nil
+		}
+		if seen[*dir.IndexNext] {
+			return fmt.Errorf("Directory %s revisits page %d", root, *dir.IndexNext)
+		}
+		seen[*dir.IndexNext] = true
+		page = dir.IndexNext
+	}
+}
+
+// DirectoryEntries returns every entry in the directory whose first page is root
+func DirectoryEntries(source LedgerEntryGetter, root Hash256) (LedgerEntrySlice, error) {
+	var entries LedgerEntrySlice
+	err := WalkDirectory(source, root, func(index Hash256, le LedgerEntry) error {
+		entries = append(entries, le)
+		return nil
+	})
+	return entries, err
+}
+
+// OwnerDirectory returns every entry owned by account, such as its
+// trust lines and offers
+func OwnerDirectory(source LedgerEntryGetter, account Account) (LedgerEntrySlice, error) {
+	root, err := GetOwnerDirectoryIndex(account)
+	if err != nil {
+		return nil, err
+	}
+	return DirectoryEntries(source, *root)
+}
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+)
+
+type DirectorySuite struct{}
+
+var _ = Suite(&DirectorySuite{})
+
+type ledgerEntryMap map[Hash256]LedgerEntry
+
+func (m ledgerEntryMap) GetLedgerEntry(index Hash256) (LedgerEntry, error) {
+	if le, ok := m[index]; ok {
+		return le, nil
+	}
+	return nil, fmt.Errorf("Not found: %s", index)
+}
+
+// addOffer stores an offer by account and returns its index
+func (m ledgerEntryMap) addOffer(c *C, account Account, sequence uint32) Hash256 {
+	index, err := GetOfferIndex(account, sequence)
+	c.Assert(err, IsNil)
+	m[*index] = &Offer{leBase: leBase{LedgerEntryType: OFFER}, Account: &account, Sequence: &sequence}
+	return *index
+}
+
+// addPage stores a page of the directory with root
+func (m ledgerEntryMap) addPage(c *C, root Hash256, page, next uint64, indexes ...Hash256) {
+	p, n := NodeIndex(page), NodeIndex(next)
+	index, err := GetDirectoryNodeIndex(root, &p)
+	c.Assert(err, IsNil)
+	if page == 0 {
+		index = &root
+	}
+	vector := Vector256(indexes)
+	m[*index] = &Directory{leBase: leBase{LedgerEntryType: DIRECTORY}, RootIndex: &root, Indexes: &vector, IndexNext: &n}
+}
+
+func (s *DirectorySuite) TestOwnerDirectory(c *C) {
+	account, err := NewAccountFromAddress("iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	c.Assert(err, IsNil)
+	root, err := GetOwnerDirectoryIndex(*account)
+	c.Assert(err, IsNil)
+
+	m := make(ledgerEntryMap)
+	var offers []Hash256
+	for sequence := uint32(1); sequence <= 5; sequence++ {
+		offers = append(offers, m.addOffer(c, *account, sequence))
+	}
+	// Pages need not be numbered consecutively
+	m.addPage(c, *root, 0, 3, offers[0], offers[1])
+	m.addPage(c, *root, 3, 1, offers[2])
+	m.addPage(c, *root, 1, 0, offers[3], offers[4])
+
+	entries, err := OwnerDirectory(m, *account)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 5)
+	for i, le := range entries {
+		c.Check(*le.(*Offer).Sequence, Equals, uint32(i+1))
+	}
+
+	// Stop early
+	count := 0
+	stop := fmt.Errorf("Stop")
+	err = WalkDirectory(m, *root, func(index Hash256, le LedgerEntry) error {
+		c.Check(index, Equals, offers[count])
+		if count++; count == 3 {
+			return stop
+		}
+		return nil
+	})
+	c.Check(err, Equals, stop)
+
+	m.addPage(c, *root, 1, 3, offers[3])
+	_, err = OwnerDirectory(m, *account)
+	c.Check(err, ErrorMatches, "Directory .* revisits page 3")
+
+	m[*root] = m[offers[0]]
+	_, err = OwnerDirectory(m, *account)
+	c.Check(err, ErrorMatches, "Not a directory: .* is Offer")
+}
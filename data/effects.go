@@ -0,0 +1,219 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+)
+
+// OfferAction describes what a transaction did to an offer
+type OfferAction uint8
+
+const (
+	OfferCreated OfferAction = iota
+	OfferPartiallyConsumed
+	OfferConsumed
+	OfferCancelled
+)
+
+var offerActions = [...]string{
+	OfferCreated:           "Created",
+	OfferPartiallyConsumed: "PartiallyConsumed",
+	OfferConsumed:          "Consumed",
+	OfferCancelled:         "Cancelled",
+}
+
+func (a OfferAction) String() string {
+	if int(a) < len(offerActions) {
+		return offerActions[a]
+	}
+	return fmt.Sprintf("Unknown(%d)", a)
+}
+
+// OfferChange is the effect of a transaction on a single offer.
+// TakerPays and TakerGets are what remains of the offer afterwards,
+// Paid and Got are what was taken from them and are nil for created
+// and cancelled offers. Offers removed because they were unfunded or
+// expired are reported as cancelled.
+type OfferChange struct {
+	Action    OfferAction
+	Account   Account
+	Sequence  uint32
+	Quality   ExchangeRate
+	TakerPays Amount
+	TakerGets Amount
+	Paid      *Amount
+	Got       *Amount
+}
+
+func (o OfferChange) String() string {
+	if o.Paid == nil {
+		return fmt.Sprintf("%-17s %-34s %8d Pays: %s Gets: %s", o.Action, o.Account, o.Sequence, o.TakerPays, o.TakerGets)
+	}
+	return fmt.Sprintf("%-17s %-34s %8d Paid: %s Got: %s", o.Action, o.Account, o.Sequence, o.Paid, o.Got)
+}
+
+type OfferChangeSlice []OfferChange
+
+func (s OfferChangeSlice) Len() int      { return len(s) }
+func (s OfferChangeSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s OfferChangeSlice) Less(i, j int) bool {
+	switch {
+	case s[i].Action != s[j].Action:
+		return s[i].Action < s[j].Action
+	case s[i].Quality != s[j].Quality:
+		return s[i].Quality.Less(s[j].Quality)
+	case !s[i].Account.Equals(s[j].Account):
+		return s[i].Account.Less(s[j].Account)
+	default:
+		return s[i].Sequence < s[j].Sequence
+	}
+}
+
+// OwnerCountChange is a change in the number of objects an account owns,
+// and so in its reserve
+type OwnerCountChange struct {
+	Account  Account
+	Previous uint32
+	Current  uint32
+}
+
+func (o OwnerCountChange) String() string {
+	return fmt.Sprintf("Account: %-34s OwnerCount: %d -> %d", o.Account, o.Previous, o.Current)
+}
+
+type OwnerCountSlice []OwnerCountChange
+
+func (s OwnerCountSlice) Len() int           { return len(s) }
+func (s OwnerCountSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s OwnerCountSlice) Less(i, j int) bool { return s[i].Account.Less(s[j].Account) }
+
+// Effects summarises everything a transaction changed in the ledger
+type Effects struct {
+	Balances    BalanceSlice
+	Offers      OfferChangeSlice
+	OwnerCounts OwnerCountSlice
+}
+
+func (txm *TransactionWithMetaData) Effects() (*Effects, error) {
+	balances, err := txm.Balances()
+	if err != nil {
+		return nil, err
+	}
+	offers, err := txm.OfferChanges()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := txm.OwnerCountChanges()
+	if err != nil {
+		return nil, err
+	}
+	return &Effects{
+		Balances:    balances,
+		Offers:      offers,
+		OwnerCounts: owners,
+	}, nil
+}
+
+// OfferChanges returns every offer created, consumed or cancelled by
+// the transaction
+func (txm *TransactionWithMetaData) OfferChanges() (OfferChangeSlice, error) {
+	var offers OfferChangeSlice
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, current, previous, state := effect.AffectedNode()
+		if node.LedgerEntryType != OFFER {
+			continue
+		}
+		change, err := newOfferChange(previous.(*Offer), current.(*Offer), state)
+		if err != nil {
+			return nil, fmt.Errorf("Offer %s: %s", node.LedgerIndex, err.Error())
+		}
+		if change != nil {
+			offers = append(offers, *change)
+		}
+	}
+	sort.Sort(offers)
+	return offers, nil
+}
+
+func newOfferChange(previous, current *Offer, state LedgerEntryState) (*OfferChange, error) {
+	changed := previous.TakerPays != nil || previous.TakerGets != nil
+	if state == Modified && !changed {
+		// Only the directory links or PreviousTxnID changed
+		return nil, nil
+	}
+	if current.Account == nil || current.Sequence == nil {
+		return nil, fmt.Errorf("missing Account or Sequence")
+	}
+	if current.TakerPays == nil || current.TakerGets == nil {
+		return nil, fmt.Errorf("missing TakerPays or TakerGets")
+	}
+	change := &OfferChange{
+		Account:   *current.Account,
+		Sequence:  *current.Sequence,
+		Quality:   current.Quality(),
+		TakerPays: *current.TakerPays,
+		TakerGets: *current.TakerGets,
+	}
+	switch {
+	case state == Created:
+		change.Action = OfferCreated
+		return change, nil
+	case !changed:
+		change.Action = OfferCancelled
+		return change, nil
+	case state == Deleted:
+		change.Action = OfferConsumed
+	default:
+		change.Action = OfferPartiallyConsumed
+	}
+	var err error
+	if change.Paid, err = offerTaken(previous.TakerPays, current.TakerPays); err != nil {
+		return nil, err
+	}
+	if change.Got, err = offerTaken(previous.TakerGets, current.TakerGets); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// offerTaken returns how much of an offer's amount was taken, which is
+// zero when the previous amount is missing because it did not change
+func offerTaken(previous, current *Amount) (*Amount, error) {
+	if previous == nil {
+		return current.ZeroClone(), nil
+	}
+	return previous.Subtract(current)
+}
+
+// OwnerCountChanges returns every account whose OwnerCount the
+// transaction changed, including created and deleted accounts
+func (txm *TransactionWithMetaData) OwnerCountChanges() (OwnerCountSlice, error) {
+	var owners OwnerCountSlice
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, current, previous, state := effect.AffectedNode()
+		if node.LedgerEntryType != ACCOUNT_ROOT {
+			continue
+		}
+		var (
+			before = previous.(*AccountRoot).OwnerCount
+			after  = current.(*AccountRoot).OwnerCount
+			zero   uint32
+		)
+		if state == Created {
+			before = &zero
+		}
+		if after == nil {
+			after = &zero
+		}
+		if before == nil || *before == *after {
+			continue
+		}
+		account := current.(*AccountRoot).Account
+		if account == nil {
+			return nil, fmt.Errorf("AccountRoot %s missing Account", node.LedgerIndex)
+		}
+		owners = append(owners, OwnerCountChange{*account, *before, *after})
+	}
+	sort.Sort(owners)
+	return owners, nil
+}
@@ -19,6 +19,24 @@ func NodeId(h Hashable) (Hash256, error) {
 	return nodeid, err
 }
 
+// LeafHash returns the hash of le stored in an account state tree at
+// index, which is the hash its parent inner node holds for it
+func LeafHash(le LedgerEntry, index Hash256) (Hash256, error) {
+	hasher := sha512.New()
+	if err := write(hasher, le.Prefix()); err != nil {
+		return zero256, err
+	}
+	if err := encode(hasher, le, false); err != nil {
+		return zero256, err
+	}
+	if err := write(hasher, index); err != nil {
+		return zero256, err
+	}
+	var hash Hash256
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
 func SigningHash(s Signer) (Hash256, []byte, error) {
 	return raw(s, s.SigningPrefix(), true)
 }
@@ -37,6 +55,91 @@ func Node(h Storer) (Hash256, []byte, error) {
 	return key, append(header.Bytes(), value...), nil
 }
 
+// Hashes holds the results of Raw, NodeId, SigningHash and SuppressionId
+type Hashes struct {
+	NodeId        Hash256
+	Raw           []byte
+	SigningHash   Hash256 // Zero for types which are not signed
+	SigningBytes  []byte
+	SuppressionId Hash256
+}
+
+// AllHashes produces every hash of h from a single pass of the encoder.
+// For a TransactionWithMetaData the signing hash and SuppressionId are
+// those of its transaction, which is encoded only once.
+func AllHashes(h Hashable) (*Hashes, error) {
+	var (
+		hashes        Hashes
+		err           error
+		full, signing bytes.Buffer
+		fullHasher    = sha512.New()
+		signingHasher = sha512.New()
+		fullWriter    = io.MultiWriter(&full, fullHasher)
+		signingWriter = io.MultiWriter(&signing, signingHasher)
+	)
+	switch v := h.(type) {
+	case *TransactionWithMetaData:
+		tx, err := AllHashes(v.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		var meta bytes.Buffer
+		if err := encode(&meta, &v.MetaData, false); err != nil {
+			return nil, err
+		}
+		if err := write(fullHasher, h.Prefix()); err != nil {
+			return nil, err
+		}
+		for _, b := range [][]byte{tx.Raw, meta.Bytes()} {
+			if err := writeVariableLength(fullWriter, b); err != nil {
+				return nil, err
+			}
+		}
+		if err := write(fullWriter, tx.NodeId); err != nil {
+			return nil, err
+		}
+		copy(hashes.NodeId[:], fullHasher.Sum(nil))
+		hashes.Raw = full.Bytes()
+		hashes.SigningHash, hashes.SigningBytes = tx.SigningHash, tx.SigningBytes
+		hashes.SuppressionId = tx.NodeId
+		return &hashes, nil
+	case *Proposal:
+		if hashes.NodeId, hashes.Raw, err = Raw(v); err != nil {
+			return nil, err
+		}
+		if hashes.SigningHash, hashes.SigningBytes, err = SigningHash(v); err != nil {
+			return nil, err
+		}
+		if hashes.SuppressionId, err = v.SuppressionId(); err != nil {
+			return nil, err
+		}
+		return &hashes, nil
+	case Transaction, *Validation:
+		if err := write(fullHasher, h.Prefix()); err != nil {
+			return nil, err
+		}
+		if err := write(signingHasher, h.(Signer).SigningPrefix()); err != nil {
+			return nil, err
+		}
+		if err := encodeSplit(fullWriter, signingWriter, h); err != nil {
+			return nil, err
+		}
+		copy(hashes.SigningHash[:], signingHasher.Sum(nil))
+		hashes.SigningBytes = signing.Bytes()
+	default:
+		if err := write(fullHasher, h.Prefix()); err != nil {
+			return nil, err
+		}
+		if err := writeRaw(fullWriter, h, false); err != nil {
+			return nil, err
+		}
+	}
+	copy(hashes.NodeId[:], fullHasher.Sum(nil))
+	hashes.Raw = full.Bytes()
+	hashes.SuppressionId = hashes.NodeId
+	return &hashes, nil
+}
+
 func raw(value interface{}, prefix HashPrefix, ignoreSigningFields bool) (Hash256, []byte, error) {
 	buf := new(bytes.Buffer)
 	hasher := sha512.New()
@@ -100,12 +203,31 @@ func writeRaw(w io.Writer, value interface{}, ignoreSigningFields bool) error {
 }
 
 func encode(w io.Writer, value interface{}, ignoreSigningFields bool) error {
+	if ignoreSigningFields {
+		return encodeSplit(nil, w, value)
+	}
+	return encodeSplit(w, nil, value)
+}
+
+// encodeSplit writes every field to full and all but the signing
+// fields to signing. Either writer may be nil.
+func encodeSplit(full, signing io.Writer, value interface{}) error {
 	v := reflect.Indirect(reflect.ValueOf(value))
 	fields := getFields(&v, 0)
 	// fmt.Println(fields.String())
+	var scratch bytes.Buffer
 	return fields.Each(func(e enc, v interface{}) error {
-		if ignoreSigningFields && e.SigningField() {
+		var w io.Writer
+		switch {
+		case signing == nil:
+			w = full
+		case full == nil && e.SigningField():
 			return nil
+		case full == nil:
+			w = signing
+		default:
+			scratch.Reset()
+			w = &scratch
 		}
 		if err := writeEncoding(w, e); err != nil {
 			return err
@@ -119,6 +241,16 @@ func encode(w io.Writer, value interface{}, ignoreSigningFields bool) error {
 		default:
 			err = write(w, v2)
 		}
+		if err != nil || w != &scratch {
+			return err
+		}
+		if _, err := full.Write(scratch.Bytes()); err != nil {
+			return err
+		}
+		if e.SigningField() {
+			return nil
+		}
+		_, err = signing.Write(scratch.Bytes())
 		return err
 	})
 }
@@ -143,28 +275,23 @@ func (s *fieldSlice) Append(e enc, v interface{}, children fieldSlice) {
 
 func getFields(v *reflect.Value, depth int) fieldSlice {
 	// fmt.Println(v, v.Kind(), v.Type().Name())
-	length := v.NumField()
-	fields := make(fieldSlice, 0, length)
-	typ := v.Type()
-	for i := 0; i < length; i++ {
-		fieldName := typ.Field(i).Name
-		if fieldName == "Hash" || fieldName == "Id" {
-			continue
-		}
+	plan := planFor(v.Type())
+	fields := make(fieldSlice, 0, len(plan.fields))
+	for _, p := range plan.fields {
 		// Stops LedgerEntryType being encoded for Fields
-		if fieldName == "LedgerEntryType" && depth > 1 && typ.Name() == "leBase" {
+		if p.topOnly && depth > 1 {
 			continue
 		}
-		encoding := reverseEncodings[fieldName]
+		encoding := p.encoding
 		// fmt.Println(fieldName, encoding, f, f.Kind())
-		f := v.Field(i)
+		f := v.Field(p.index)
 		if f.Kind() == reflect.Interface {
 			f = f.Elem()
 		}
 		if f.Kind() == reflect.Ptr {
 			f = f.Elem()
 		}
-		if !f.IsValid() || !f.CanInterface() || (f.Kind() == reflect.Slice && f.Len() == 0) {
+		if !f.IsValid() || (f.Kind() == reflect.Slice && f.Len() == 0) {
 			continue
 		}
 		switch encoding.typ {
@@ -7,22 +7,32 @@ type LedgerEntryType uint16
 type TransactionType uint16
 
 const (
-	ACCOUNT_ROOT  LedgerEntryType = 0x61 // 'a'
-	DIRECTORY     LedgerEntryType = 0x64 // 'd'
-	AMENDMENTS    LedgerEntryType = 0x66 // 'f'
-	LEDGER_HASHES LedgerEntryType = 0x68 // 'h'
-	OFFER         LedgerEntryType = 0x6f // 'o'
-	RIPPLE_STATE  LedgerEntryType = 0x72 // 'r'
-	FEE_SETTINGS  LedgerEntryType = 0x73 // 's'
-
-	PAYMENT         TransactionType = 0
-	ACCOUNT_SET     TransactionType = 3
-	SET_REGULAR_KEY TransactionType = 5
-	OFFER_CREATE    TransactionType = 7
-	OFFER_CANCEL    TransactionType = 8
-	TRUST_SET       TransactionType = 20
-	AMENDMENT       TransactionType = 100
-	SET_FEE         TransactionType = 101
+	NFTOKEN_OFFER   LedgerEntryType = 0x37 // '7'
+	NFTOKEN_PAGE    LedgerEntryType = 0x50 // 'P'
+	ACCOUNT_ROOT    LedgerEntryType = 0x61 // 'a'
+	DIRECTORY       LedgerEntryType = 0x64 // 'd'
+	AMENDMENTS      LedgerEntryType = 0x66 // 'f'
+	LEDGER_HASHES   LedgerEntryType = 0x68 // 'h'
+	OFFER           LedgerEntryType = 0x6f // 'o'
+	DEPOSIT_PREAUTH LedgerEntryType = 0x70 // 'p'
+	RIPPLE_STATE    LedgerEntryType = 0x72 // 'r'
+	FEE_SETTINGS    LedgerEntryType = 0x73 // 's'
+
+	PAYMENT              TransactionType = 0
+	ACCOUNT_SET          TransactionType = 3
+	SET_REGULAR_KEY      TransactionType = 5
+	OFFER_CREATE         TransactionType = 7
+	OFFER_CANCEL         TransactionType = 8
+	SET_DEPOSIT_PREAUTH  TransactionType = 19
+	TRUST_SET            TransactionType = 20
+	ACCOUNT_DELETE       TransactionType = 21
+	NFTOKEN_MINT         TransactionType = 25
+	NFTOKEN_BURN         TransactionType = 26
+	NFTOKEN_CREATE_OFFER TransactionType = 27
+	NFTOKEN_CANCEL_OFFER TransactionType = 28
+	NFTOKEN_ACCEPT_OFFER TransactionType = 29
+	AMENDMENT            TransactionType = 100
+	SET_FEE              TransactionType = 101
 )
 
 var LedgerFactory = [...]func() Hashable{
@@ -30,66 +40,96 @@ var LedgerFactory = [...]func() Hashable{
 }
 
 var LedgerEntryFactory = [...]func() LedgerEntry{
-	ACCOUNT_ROOT:  func() LedgerEntry { return &AccountRoot{leBase: leBase{LedgerEntryType: ACCOUNT_ROOT}} },
-	DIRECTORY:     func() LedgerEntry { return &Directory{leBase: leBase{LedgerEntryType: DIRECTORY}} },
-	AMENDMENTS:    func() LedgerEntry { return &Amendments{leBase: leBase{LedgerEntryType: AMENDMENTS}} },
-	LEDGER_HASHES: func() LedgerEntry { return &LedgerHashes{leBase: leBase{LedgerEntryType: LEDGER_HASHES}} },
-	OFFER:         func() LedgerEntry { return &Offer{leBase: leBase{LedgerEntryType: OFFER}} },
-	RIPPLE_STATE:  func() LedgerEntry { return &RippleState{leBase: leBase{LedgerEntryType: RIPPLE_STATE}} },
-	FEE_SETTINGS:  func() LedgerEntry { return &FeeSettings{leBase: leBase{LedgerEntryType: FEE_SETTINGS}} },
+	ACCOUNT_ROOT:    func() LedgerEntry { return &AccountRoot{leBase: leBase{LedgerEntryType: ACCOUNT_ROOT}} },
+	DIRECTORY:       func() LedgerEntry { return &Directory{leBase: leBase{LedgerEntryType: DIRECTORY}} },
+	AMENDMENTS:      func() LedgerEntry { return &Amendments{leBase: leBase{LedgerEntryType: AMENDMENTS}} },
+	LEDGER_HASHES:   func() LedgerEntry { return &LedgerHashes{leBase: leBase{LedgerEntryType: LEDGER_HASHES}} },
+	OFFER:           func() LedgerEntry { return &Offer{leBase: leBase{LedgerEntryType: OFFER}} },
+	RIPPLE_STATE:    func() LedgerEntry { return &RippleState{leBase: leBase{LedgerEntryType: RIPPLE_STATE}} },
+	FEE_SETTINGS:    func() LedgerEntry { return &FeeSettings{leBase: leBase{LedgerEntryType: FEE_SETTINGS}} },
+	DEPOSIT_PREAUTH: func() LedgerEntry { return &Preauthorization{leBase: leBase{LedgerEntryType: DEPOSIT_PREAUTH}} },
+	NFTOKEN_OFFER:   func() LedgerEntry { return &NFTokenOffer{leBase: leBase{LedgerEntryType: NFTOKEN_OFFER}} },
+	NFTOKEN_PAGE:    func() LedgerEntry { return &NFTokenPage{leBase: leBase{LedgerEntryType: NFTOKEN_PAGE}} },
 }
 
 var TxFactory = [...]func() Transaction{
-	PAYMENT:         func() Transaction { return &Payment{TxBase: TxBase{TransactionType: PAYMENT}} },
-	ACCOUNT_SET:     func() Transaction { return &AccountSet{TxBase: TxBase{TransactionType: ACCOUNT_SET}} },
-	SET_REGULAR_KEY: func() Transaction { return &SetRegularKey{TxBase: TxBase{TransactionType: SET_REGULAR_KEY}} },
-	OFFER_CREATE:    func() Transaction { return &OfferCreate{TxBase: TxBase{TransactionType: OFFER_CREATE}} },
-	OFFER_CANCEL:    func() Transaction { return &OfferCancel{TxBase: TxBase{TransactionType: OFFER_CANCEL}} },
-	TRUST_SET:       func() Transaction { return &TrustSet{TxBase: TxBase{TransactionType: TRUST_SET}} },
-	AMENDMENT:       func() Transaction { return &Amendment{TxBase: TxBase{TransactionType: AMENDMENT}} },
-	SET_FEE:         func() Transaction { return &SetFee{TxBase: TxBase{TransactionType: SET_FEE}} },
+	PAYMENT:              func() Transaction { return &Payment{TxBase: TxBase{TransactionType: PAYMENT}} },
+	ACCOUNT_SET:          func() Transaction { return &AccountSet{TxBase: TxBase{TransactionType: ACCOUNT_SET}} },
+	SET_REGULAR_KEY:      func() Transaction { return &SetRegularKey{TxBase: TxBase{TransactionType: SET_REGULAR_KEY}} },
+	OFFER_CREATE:         func() Transaction { return &OfferCreate{TxBase: TxBase{TransactionType: OFFER_CREATE}} },
+	OFFER_CANCEL:         func() Transaction { return &OfferCancel{TxBase: TxBase{TransactionType: OFFER_CANCEL}} },
+	SET_DEPOSIT_PREAUTH:  func() Transaction { return &DepositPreauth{TxBase: TxBase{TransactionType: SET_DEPOSIT_PREAUTH}} },
+	TRUST_SET:            func() Transaction { return &TrustSet{TxBase: TxBase{TransactionType: TRUST_SET}} },
+	ACCOUNT_DELETE:       func() Transaction { return &AccountDelete{TxBase: TxBase{TransactionType: ACCOUNT_DELETE}} },
+	NFTOKEN_MINT:         func() Transaction { return &NFTokenMint{TxBase: TxBase{TransactionType: NFTOKEN_MINT}} },
+	NFTOKEN_BURN:         func() Transaction { return &NFTokenBurn{TxBase: TxBase{TransactionType: NFTOKEN_BURN}} },
+	NFTOKEN_CREATE_OFFER: func() Transaction { return &NFTokenCreateOffer{TxBase: TxBase{TransactionType: NFTOKEN_CREATE_OFFER}} },
+	NFTOKEN_CANCEL_OFFER: func() Transaction { return &NFTokenCancelOffer{TxBase: TxBase{TransactionType: NFTOKEN_CANCEL_OFFER}} },
+	NFTOKEN_ACCEPT_OFFER: func() Transaction { return &NFTokenAcceptOffer{TxBase: TxBase{TransactionType: NFTOKEN_ACCEPT_OFFER}} },
+	AMENDMENT:            func() Transaction { return &Amendment{TxBase: TxBase{TransactionType: AMENDMENT}} },
+	SET_FEE:              func() Transaction { return &SetFee{TxBase: TxBase{TransactionType: SET_FEE}} },
 }
 
 var ledgerEntryNames = [...]string{
-	ACCOUNT_ROOT:  "AccountRoot",
-	DIRECTORY:     "DirectoryNode",
-	AMENDMENTS:    "Amendments",
-	LEDGER_HASHES: "LedgerHashes",
-	OFFER:         "Offer",
-	RIPPLE_STATE:  "RippleState",
-	FEE_SETTINGS:  "FeeSettings",
+	ACCOUNT_ROOT:    "AccountRoot",
+	DIRECTORY:       "DirectoryNode",
+	AMENDMENTS:      "Amendments",
+	LEDGER_HASHES:   "LedgerHashes",
+	OFFER:           "Offer",
+	RIPPLE_STATE:    "RippleState",
+	FEE_SETTINGS:    "FeeSettings",
+	DEPOSIT_PREAUTH: "DepositPreauth",
+	NFTOKEN_OFFER:   "NFTokenOffer",
+	NFTOKEN_PAGE:    "NFTokenPage",
 }
 
 var ledgerEntryTypes = map[string]LedgerEntryType{
-	"AccountRoot":   ACCOUNT_ROOT,
-	"DirectoryNode": DIRECTORY,
-	"Amendments":    AMENDMENTS,
-	"LedgerHashes":  LEDGER_HASHES,
-	"Offer":         OFFER,
-	"RippleState":   RIPPLE_STATE,
-	"FeeSettings":   FEE_SETTINGS,
+	"AccountRoot":    ACCOUNT_ROOT,
+	"DirectoryNode":  DIRECTORY,
+	"Amendments":     AMENDMENTS,
+	"LedgerHashes":   LEDGER_HASHES,
+	"Offer":          OFFER,
+	"RippleState":    RIPPLE_STATE,
+	"FeeSettings":    FEE_SETTINGS,
+	"DepositPreauth": DEPOSIT_PREAUTH,
+	"NFTokenOffer":   NFTOKEN_OFFER,
+	"NFTokenPage":    NFTOKEN_PAGE,
 }
 
 var txNames = [...]string{
-	PAYMENT:         "Payment",
-	ACCOUNT_SET:     "AccountSet",
-	SET_REGULAR_KEY: "SetRegularKey",
-	OFFER_CREATE:    "OfferCreate",
-	OFFER_CANCEL:    "OfferCancel",
-	TRUST_SET:       "TrustSet",
-	AMENDMENT:       "Amendment",
-	SET_FEE:         "SetFee",
+	PAYMENT:              "Payment",
+	ACCOUNT_SET:          "AccountSet",
+	SET_REGULAR_KEY:      "SetRegularKey",
+	OFFER_CREATE:         "OfferCreate",
+	OFFER_CANCEL:         "OfferCancel",
+	SET_DEPOSIT_PREAUTH:  "DepositPreauth",
+	TRUST_SET:            "TrustSet",
+	ACCOUNT_DELETE:       "AccountDelete",
+	NFTOKEN_MINT:         "NFTokenMint",
+	NFTOKEN_BURN:         "NFTokenBurn",
+	NFTOKEN_CREATE_OFFER: "NFTokenCreateOffer",
+	NFTOKEN_CANCEL_OFFER: "NFTokenCancelOffer",
+	NFTOKEN_ACCEPT_OFFER: "NFTokenAcceptOffer",
+	AMENDMENT:            "Amendment",
+	SET_FEE:              "SetFee",
 }
 
 var txTypes = map[string]TransactionType{
-	"Payment":       PAYMENT,
-	"AccountSet":    ACCOUNT_SET,
-	"SetRegularKey": SET_REGULAR_KEY,
-	"OfferCreate":   OFFER_CREATE,
-	"OfferCancel":   OFFER_CANCEL,
-	"TrustSet":      TRUST_SET,
-	"Amendment":     AMENDMENT,
-	"SetFee":        SET_FEE,
+	"Payment":            PAYMENT,
+	"AccountSet":         ACCOUNT_SET,
+	"SetRegularKey":      SET_REGULAR_KEY,
+	"OfferCreate":        OFFER_CREATE,
+	"OfferCancel":        OFFER_CANCEL,
+	"DepositPreauth":     SET_DEPOSIT_PREAUTH,
+	"TrustSet":           TRUST_SET,
+	"AccountDelete":      ACCOUNT_DELETE,
+	"NFTokenMint":        NFTOKEN_MINT,
+	"NFTokenBurn":        NFTOKEN_BURN,
+	"NFTokenCreateOffer": NFTOKEN_CREATE_OFFER,
+	"NFTokenCancelOffer": NFTOKEN_CANCEL_OFFER,
+	"NFTokenAcceptOffer": NFTOKEN_ACCEPT_OFFER,
+	"Amendment":          AMENDMENT,
+	"SetFee":             SET_FEE,
 }
 
 var HashableTypes []string
@@ -110,17 +150,45 @@ func init() {
 }
 
 func (t TransactionType) String() string {
-	return txNames[t]
+	if name, ok := definedTxNames[t]; ok {
+		return name
+	}
+	if int(t) < len(txNames) {
+		return txNames[t]
+	}
+	return ""
 }
 
 func (le LedgerEntryType) String() string {
-	return ledgerEntryNames[le]
+	if name, ok := definedLedgerEntryNames[le]; ok {
+		return name
+	}
+	if int(le) < len(ledgerEntryNames) {
+		return ledgerEntryNames[le]
+	}
+	return ""
+}
+
+// txFactory returns nil for types with no concrete Transaction
+func txFactory(t TransactionType) func() Transaction {
+	if int(t) < len(TxFactory) {
+		return TxFactory[t]
+	}
+	return nil
+}
+
+// ledgerEntryFactory returns nil for types with no concrete LedgerEntry
+func ledgerEntryFactory(le LedgerEntryType) func() LedgerEntry {
+	if int(le) < len(LedgerEntryFactory) {
+		return LedgerEntryFactory[le]
+	}
+	return nil
 }
 
 func GetTxFactoryByType(txType string) func() Transaction {
-	return TxFactory[txTypes[txType]]
+	return txFactory(txTypes[txType])
 }
 
 func GetLedgerEntryFactoryByType(leType string) func() LedgerEntry {
-	return LedgerEntryFactory[ledgerEntryTypes[leType]]
+	return ledgerEntryFactory(ledgerEntryTypes[leType])
 }
@@ -19,19 +19,12 @@ const (
 	TxCircle         TransactionFlag = 0x00080000 // Not implemented
 
 	// AccountSet flags
-	TxSetRequireDest   TransactionFlag = 0x00000001
-	TxSetRequireAuth   TransactionFlag = 0x00000002
-	TxSetDisallowXRP   TransactionFlag = 0x00000003
-	TxSetDisableMaster TransactionFlag = 0x00000004
-	TxSetAccountTxnID  TransactionFlag = 0x00000005
-	TxNoFreeze         TransactionFlag = 0x00000006
-	TxGlobalFreeze     TransactionFlag = 0x00000007
-	TxRequireDestTag   TransactionFlag = 0x00010000
-	TxOptionalDestTag  TransactionFlag = 0x00020000
-	TxRequireAuth      TransactionFlag = 0x00040000
-	TxOptionalAuth     TransactionFlag = 0x00080000
-	TxDisallowXRP      TransactionFlag = 0x00100000
-	TxAllowXRP         TransactionFlag = 0x00200000
+	TxRequireDestTag  TransactionFlag = 0x00010000
+	TxOptionalDestTag TransactionFlag = 0x00020000
+	TxRequireAuth     TransactionFlag = 0x00040000
+	TxOptionalAuth    TransactionFlag = 0x00080000
+	TxDisallowXRP     TransactionFlag = 0x00100000
+	TxAllowXRP        TransactionFlag = 0x00200000
 
 	// OfferCreate flags
 	TxPassive           TransactionFlag = 0x00010000
@@ -45,6 +38,43 @@ const (
 	TxClearNoRipple TransactionFlag = 0x00040000
 	TxSetFreeze     TransactionFlag = 0x00100000
 	TxClearFreeze   TransactionFlag = 0x00200000
+
+	// NFTokenMint flags
+	TxBurnable     TransactionFlag = 0x00000001
+	TxOnlyXRP      TransactionFlag = 0x00000002
+	TxTrustLine    TransactionFlag = 0x00000004
+	TxTransferable TransactionFlag = 0x00000008
+
+	// NFTokenCreateOffer flags
+	TxSellNFToken TransactionFlag = 0x00000001
+)
+
+// AccountSetFlag is the value of an AccountSet's SetFlag or ClearFlag
+type AccountSetFlag uint32
+
+const (
+	AsfRequireDest             AccountSetFlag = 1
+	AsfRequireAuth             AccountSetFlag = 2
+	AsfDisallowXRP             AccountSetFlag = 3
+	AsfDisableMaster           AccountSetFlag = 4
+	AsfAccountTxnID            AccountSetFlag = 5
+	AsfNoFreeze                AccountSetFlag = 6
+	AsfGlobalFreeze            AccountSetFlag = 7
+	AsfDefaultRipple           AccountSetFlag = 8
+	AsfDepositAuth             AccountSetFlag = 9
+	AsfAuthorizedNFTokenMinter AccountSetFlag = 10
+)
+
+// Deprecated: these are SetFlag and ClearFlag values rather than
+// transaction flags. Use the AccountSetFlag constants instead.
+const (
+	TxSetRequireDest   = TransactionFlag(AsfRequireDest)
+	TxSetRequireAuth   = TransactionFlag(AsfRequireAuth)
+	TxSetDisallowXRP   = TransactionFlag(AsfDisallowXRP)
+	TxSetDisableMaster = TransactionFlag(AsfDisableMaster)
+	TxSetAccountTxnID  = TransactionFlag(AsfAccountTxnID)
+	TxNoFreeze         = TransactionFlag(AsfNoFreeze)
+	TxGlobalFreeze     = TransactionFlag(AsfGlobalFreeze)
 )
 
 // Ledger entry flags
@@ -56,6 +86,9 @@ const (
 	LsDisallowXRP    LedgerEntryFlag = 0x00080000
 	LsDisableMaster  LedgerEntryFlag = 0x00100000
 	LsNoFreeze       LedgerEntryFlag = 0x00200000
+	LsGlobalFreeze   LedgerEntryFlag = 0x00400000
+	LsDefaultRipple  LedgerEntryFlag = 0x00800000
+	LsDepositAuth    LedgerEntryFlag = 0x01000000
 
 	// Offer flags
 	LsPassive LedgerEntryFlag = 0x00010000
@@ -70,6 +103,9 @@ const (
 	LsHighNoRipple LedgerEntryFlag = 0x00200000
 	LsLowFreeze    LedgerEntryFlag = 0x00400000
 	LsHighFreeze   LedgerEntryFlag = 0x00800000
+
+	// NFTokenOffer flags
+	LsSellNFToken LedgerEntryFlag = 0x00000001
 )
 
 var txFlagNames = map[TransactionType][]struct {
@@ -83,15 +119,10 @@ var txFlagNames = map[TransactionType][]struct {
 		{TxCircle, "Circle"},
 	},
 	ACCOUNT_SET: {
-		{TxSetRequireDest, "SetRequireDest"},
-		{TxSetRequireAuth, "SetRequireAuth"},
-		{TxSetDisallowXRP, "SetDisallowXRP"},
-		{TxSetDisableMaster, "SetDisableMaster"},
-		{TxNoFreeze, "NoFreeze"},
-		{TxGlobalFreeze, "GlobalFreeze"},
 		{TxRequireDestTag, "RequireDestTag"},
 		{TxOptionalDestTag, "OptionalDestTag"},
 		{TxRequireAuth, "RequireAuth"},
+		{TxOptionalAuth, "OptionalAuth"},
 		{TxDisallowXRP, "DisallowXRP"},
 		{TxAllowXRP, "AllowXRP"},
 	},
@@ -108,6 +139,15 @@ var txFlagNames = map[TransactionType][]struct {
 		{TxSetFreeze, "SetFreeze"},
 		{TxClearFreeze, "ClearFreeze"},
 	},
+	NFTOKEN_MINT: {
+		{TxBurnable, "Burnable"},
+		{TxOnlyXRP, "OnlyXRP"},
+		{TxTrustLine, "TrustLine"},
+		{TxTransferable, "Transferable"},
+	},
+	NFTOKEN_CREATE_OFFER: {
+		{TxSellNFToken, "SellNFToken"},
+	},
 }
 
 var leFlagNames = map[LedgerEntryType][]struct {
@@ -121,6 +161,9 @@ var leFlagNames = map[LedgerEntryType][]struct {
 		{LsDisallowXRP, "DisallowXRP"},
 		{LsDisableMaster, "DisableMaster"},
 		{LsNoFreeze, "NoFreeze"},
+		{LsGlobalFreeze, "GlobalFreeze"},
+		{LsDefaultRipple, "DefaultRipple"},
+		{LsDepositAuth, "DepositAuth"},
 	},
 	OFFER: {
 		{LsPassive, "Passive"},
@@ -136,6 +179,45 @@ var leFlagNames = map[LedgerEntryType][]struct {
 		{LsLowFreeze, "LowFreeze"},
 		{LsHighFreeze, "HighFreeze"},
 	},
+	NFTOKEN_OFFER: {
+		{LsSellNFToken, "SellNFToken"},
+	},
+}
+
+// accountSetFlags names each AccountSetFlag and gives the AccountRoot
+// flag it controls, if any
+var accountSetFlags = map[AccountSetFlag]struct {
+	Name string
+	Flag LedgerEntryFlag
+}{
+	AsfRequireDest:             {"RequireDest", LsRequireDestTag},
+	AsfRequireAuth:             {"RequireAuth", LsRequireAuth},
+	AsfDisallowXRP:             {"DisallowXRP", LsDisallowXRP},
+	AsfDisableMaster:           {"DisableMaster", LsDisableMaster},
+	AsfAccountTxnID:            {"AccountTxnID", 0},
+	AsfNoFreeze:                {"NoFreeze", LsNoFreeze},
+	AsfGlobalFreeze:            {"GlobalFreeze", LsGlobalFreeze},
+	AsfDefaultRipple:           {"DefaultRipple", LsDefaultRipple},
+	AsfDepositAuth:             {"DepositAuth", LsDepositAuth},
+	AsfAuthorizedNFTokenMinter: {"AuthorizedNFTokenMinter", 0},
+}
+
+func (f AccountSetFlag) IsValid() bool {
+	_, ok := accountSetFlags[f]
+	return ok
+}
+
+// LedgerEntryFlag returns the AccountRoot flag controlled by f, which is
+// zero for those flags which instead set a field
+func (f AccountSetFlag) LedgerEntryFlag() LedgerEntryFlag {
+	return accountSetFlags[f].Flag
+}
+
+func (f AccountSetFlag) String() string {
+	if n, ok := accountSetFlags[f]; ok {
+		return n.Name
+	}
+	return fmt.Sprintf("Unknown(%d)", uint32(f))
 }
 
 func (f TransactionFlag) String() string {
@@ -168,3 +250,54 @@ func (f LedgerEntryFlag) Explain(le LedgerEntry) []string {
 	}
 	return flags
 }
+
+// ExplainFlags describes the AccountSetFlags set and cleared by tx, with
+// a leading + or - respectively
+func (tx *AccountSet) ExplainFlags() []string {
+	var flags []string
+	if tx.SetFlag != nil {
+		flags = append(flags, "+"+tx.SetFlag.String())
+	}
+	if tx.ClearFlag != nil {
+		flags = append(flags, "-"+tx.ClearFlag.String())
+	}
+	return flags
+}
+
+// AccountRootFlags returns the flags of the sending account's AccountRoot
+// after applying tx to flags, as rippled would were it to succeed
+func (tx *AccountSet) AccountRootFlags(flags LedgerEntryFlag) LedgerEntryFlag {
+	if tx.Flags != nil {
+		for _, f := range []struct {
+			set, clear TransactionFlag
+			flag       LedgerEntryFlag
+		}{
+			{TxRequireDestTag, TxOptionalDestTag, LsRequireDestTag},
+			{TxRequireAuth, TxOptionalAuth, LsRequireAuth},
+			{TxDisallowXRP, TxAllowXRP, LsDisallowXRP},
+		} {
+			switch {
+			case *tx.Flags&f.set != 0:
+				flags |= f.flag
+			case *tx.Flags&f.clear != 0:
+				flags &^= f.flag
+			}
+		}
+	}
+	if tx.SetFlag != nil {
+		flags |= tx.SetFlag.LedgerEntryFlag()
+	}
+	if tx.ClearFlag != nil {
+		switch *tx.ClearFlag {
+		case AsfNoFreeze:
+			// NoFreeze is permanent
+		case AsfGlobalFreeze:
+			if flags&LsNoFreeze == 0 {
+				flags &^= LsGlobalFreeze
+			}
+		default:
+			flags &^= tx.ClearFlag.LedgerEntryFlag()
+		}
+	}
+	return flags
+}
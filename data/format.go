@@ -36,15 +36,19 @@ const (
 	NF_WIRE   NodeFormat = 3
 
 	// Ledger index NameSpaces
-	NS_ACCOUNT         LedgerNamespace = 'a'
-	NS_DIRECTORY_NODE  LedgerNamespace = 'd'
-	NS_RIPPLE_STATE    LedgerNamespace = 'r'
-	NS_OFFER           LedgerNamespace = 'o' // Entry for an offer
-	NS_OWNER_DIRECTORY LedgerNamespace = 'O' // Directory of things owned by an account
-	NS_BOOK_DIRECTORY  LedgerNamespace = 'B' // Directory of order books
-	NS_SKIP_LIST       LedgerNamespace = 's'
-	NS_AMENDMENT       LedgerNamespace = 'f'
-	NS_FEE             LedgerNamespace = 'e'
+	NS_ACCOUNT             LedgerNamespace = 'a'
+	NS_DIRECTORY_NODE      LedgerNamespace = 'd'
+	NS_RIPPLE_STATE        LedgerNamespace = 'r'
+	NS_OFFER               LedgerNamespace = 'o' // Entry for an offer
+	NS_OWNER_DIRECTORY     LedgerNamespace = 'O' // Directory of things owned by an account
+	NS_BOOK_DIRECTORY      LedgerNamespace = 'B' // Directory of order books
+	NS_SKIP_LIST           LedgerNamespace = 's'
+	NS_AMENDMENT           LedgerNamespace = 'f'
+	NS_FEE                 LedgerNamespace = 'e'
+	NS_DEPOSIT_PREAUTH     LedgerNamespace = 'p' // Entry for a preauthorized sender
+	NS_NFTOKEN_OFFER       LedgerNamespace = 'q' // Entry for an NFToken offer
+	NS_NFTOKEN_BUY_OFFERS  LedgerNamespace = 'h' // Directory of buy offers for an NFToken
+	NS_NFTOKEN_SELL_OFFERS LedgerNamespace = 'i' // Directory of sell offers for an NFToken
 )
 
 var nodeTypes = [...]string{
@@ -86,6 +90,7 @@ var encodings = map[enc]string{
 	// 16-bit unsigned integers (common)
 	enc{ST_UINT16, 1}: "LedgerEntryType",
 	enc{ST_UINT16, 2}: "TransactionType",
+	enc{ST_UINT16, 4}: "TransferFee",
 	// 32-bit unsigned integers (common)
 	enc{ST_UINT32, 2}:  "Flags",
 	enc{ST_UINT32, 3}:  "SourceTag",
@@ -120,6 +125,10 @@ var encodings = map[enc]string{
 	enc{ST_UINT32, 32}: "ReserveIncrement",
 	enc{ST_UINT32, 33}: "SetFlag",
 	enc{ST_UINT32, 34}: "ClearFlag",
+	enc{ST_UINT32, 42}: "NFTokenTaxon",
+	enc{ST_UINT32, 43}: "MintedNFTokens",
+	enc{ST_UINT32, 44}: "BurnedNFTokens",
+	enc{ST_UINT32, 50}: "FirstNFTokenSequence",
 	// 64-bit unsigned integers (common)
 	enc{ST_UINT64, 1}: "IndexNext",
 	enc{ST_UINT64, 2}: "IndexPrevious",
@@ -129,23 +138,30 @@ var encodings = map[enc]string{
 	enc{ST_UINT64, 6}: "ExchangeRate",
 	enc{ST_UINT64, 7}: "LowNode",
 	enc{ST_UINT64, 8}: "HighNode",
+	// 64-bit unsigned integers (uncommon)
+	enc{ST_UINT64, 12}: "NFTokenOfferNode",
 	// 128-bit (common)
 	enc{ST_HASH128, 1}: "EmailHash",
 	// 256-bit (common)
-	enc{ST_HASH256, 1}: "LedgerHash",
-	enc{ST_HASH256, 2}: "ParentHash",
-	enc{ST_HASH256, 3}: "TransactionHash",
-	enc{ST_HASH256, 4}: "AccountHash",
-	enc{ST_HASH256, 5}: "PreviousTxnID",
-	enc{ST_HASH256, 6}: "LedgerIndex",
-	enc{ST_HASH256, 7}: "WalletLocator",
-	enc{ST_HASH256, 8}: "RootIndex",
-	enc{ST_HASH256, 9}: "AccountTxnID",
+	enc{ST_HASH256, 1}:  "LedgerHash",
+	enc{ST_HASH256, 2}:  "ParentHash",
+	enc{ST_HASH256, 3}:  "TransactionHash",
+	enc{ST_HASH256, 4}:  "AccountHash",
+	enc{ST_HASH256, 5}:  "PreviousTxnID",
+	enc{ST_HASH256, 6}:  "LedgerIndex",
+	enc{ST_HASH256, 7}:  "WalletLocator",
+	enc{ST_HASH256, 8}:  "RootIndex",
+	enc{ST_HASH256, 9}:  "AccountTxnID",
+	enc{ST_HASH256, 10}: "NFTokenID",
 	// 256-bit (uncommon)
 	enc{ST_HASH256, 16}: "BookDirectory",
 	enc{ST_HASH256, 17}: "InvoiceID",
 	enc{ST_HASH256, 18}: "Nickname",
 	enc{ST_HASH256, 19}: "Amendment",
+	enc{ST_HASH256, 26}: "NextPageMin",
+	enc{ST_HASH256, 27}: "PreviousPageMin",
+	enc{ST_HASH256, 28}: "NFTokenBuyOffer",
+	enc{ST_HASH256, 29}: "NFTokenSellOffer",
 	// currency amount (common)
 	enc{ST_AMOUNT, 1}: "Amount",
 	enc{ST_AMOUNT, 2}: "Balance",
@@ -160,12 +176,13 @@ var encodings = map[enc]string{
 	enc{ST_AMOUNT, 16}: "MinimumOffer",
 	enc{ST_AMOUNT, 17}: "RippleEscrow",
 	enc{ST_AMOUNT, 18}: "DeliveredAmount",
+	enc{ST_AMOUNT, 19}: "NFTokenBrokerFee",
 	// variable length (common)
 	enc{ST_VL, 1}:  "PublicKey",
 	enc{ST_VL, 2}:  "MessageKey",
 	enc{ST_VL, 3}:  "SigningPubKey",
 	enc{ST_VL, 4}:  "TxnSignature",
-	enc{ST_VL, 5}:  "Generator",
+	enc{ST_VL, 5}:  "URI",
 	enc{ST_VL, 6}:  "Signature",
 	enc{ST_VL, 7}:  "Domain",
 	enc{ST_VL, 8}:  "FundCode",
@@ -174,13 +191,17 @@ var encodings = map[enc]string{
 	enc{ST_VL, 11}: "CreateCode",
 	enc{ST_VL, 12}: "MemoType",
 	enc{ST_VL, 13}: "MemoData",
+	enc{ST_VL, 14}: "MemoFormat",
 	// account
 	enc{ST_ACCOUNT, 1}: "Account",
 	enc{ST_ACCOUNT, 2}: "Owner",
 	enc{ST_ACCOUNT, 3}: "Destination",
 	enc{ST_ACCOUNT, 4}: "Issuer",
+	enc{ST_ACCOUNT, 5}: "Authorize",
+	enc{ST_ACCOUNT, 6}: "Unauthorize",
 	enc{ST_ACCOUNT, 7}: "Target",
 	enc{ST_ACCOUNT, 8}: "RegularKey",
+	enc{ST_ACCOUNT, 9}: "NFTokenMinter",
 	// inner object
 	enc{ST_OBJECT, 1}:  "EndOfObject",
 	enc{ST_OBJECT, 2}:  "TransactionMetaData",
@@ -192,16 +213,18 @@ var encodings = map[enc]string{
 	enc{ST_OBJECT, 8}:  "NewFields",
 	enc{ST_OBJECT, 9}:  "TemplateEntry",
 	enc{ST_OBJECT, 10}: "Memo",
+	enc{ST_OBJECT, 12}: "NFToken",
 	// array of objects
-	enc{ST_ARRAY, 1}: "EndOfArray",
-	enc{ST_ARRAY, 2}: "SigningAccounts",
-	enc{ST_ARRAY, 3}: "TxnSignatures",
-	enc{ST_ARRAY, 4}: "Signatures",
-	enc{ST_ARRAY, 5}: "Template",
-	enc{ST_ARRAY, 6}: "Necessary",
-	enc{ST_ARRAY, 7}: "Sufficient",
-	enc{ST_ARRAY, 8}: "AffectedNodes",
-	enc{ST_ARRAY, 9}: "Memos",
+	enc{ST_ARRAY, 1}:  "EndOfArray",
+	enc{ST_ARRAY, 2}:  "SigningAccounts",
+	enc{ST_ARRAY, 3}:  "TxnSignatures",
+	enc{ST_ARRAY, 4}:  "Signatures",
+	enc{ST_ARRAY, 5}:  "Template",
+	enc{ST_ARRAY, 6}:  "Necessary",
+	enc{ST_ARRAY, 7}:  "Sufficient",
+	enc{ST_ARRAY, 8}:  "AffectedNodes",
+	enc{ST_ARRAY, 9}:  "Memos",
+	enc{ST_ARRAY, 10}: "NFTokens",
 	// 8-bit unsigned integers (common)
 	enc{ST_UINT8, 1}: "CloseResolution",
 	enc{ST_UINT8, 2}: "TemplateEntryType",
@@ -217,6 +240,113 @@ var encodings = map[enc]string{
 	enc{ST_VECTOR256, 1}: "Indexes",
 	enc{ST_VECTOR256, 2}: "Hashes",
 	enc{ST_VECTOR256, 3}: "Amendments",
+	enc{ST_VECTOR256, 4}: "NFTokenOffers",
+}
+
+// Whether a field of a transaction format must be present
+type fieldRequirement uint8
+
+const (
+	soeOptional fieldRequirement = iota
+	soeRequired
+)
+
+// commonTxFormat lists the fields shared by every transaction type
+var commonTxFormat = map[string]fieldRequirement{
+	"TransactionType":    soeRequired,
+	"Flags":              soeOptional,
+	"SourceTag":          soeOptional,
+	"Account":            soeRequired,
+	"Sequence":           soeRequired,
+	"Fee":                soeRequired,
+	"AccountTxnID":       soeOptional,
+	"SigningPubKey":      soeOptional, // Required once signed
+	"TxnSignature":       soeOptional,
+	"Memos":              soeOptional,
+	"PreviousTxnID":      soeOptional,
+	"LastLedgerSequence": soeOptional,
+}
+
+// txFormats lists the fields particular to each transaction type, as in
+// rippled's TxFormats
+var txFormats = map[TransactionType]map[string]fieldRequirement{
+	PAYMENT: {
+		"Destination":    soeRequired,
+		"Amount":         soeRequired,
+		"SendMax":        soeOptional,
+		"Paths":          soeOptional,
+		"DestinationTag": soeOptional,
+		"InvoiceID":      soeOptional,
+	},
+	ACCOUNT_SET: {
+		"EmailHash":     soeOptional,
+		"WalletLocator": soeOptional,
+		"WalletSize":    soeOptional,
+		"MessageKey":    soeOptional,
+		"Domain":        soeOptional,
+		"TransferRate":  soeOptional,
+		"SetFlag":       soeOptional,
+		"ClearFlag":     soeOptional,
+	},
+	SET_REGULAR_KEY: {
+		"RegularKey": soeOptional,
+	},
+	OFFER_CREATE: {
+		"TakerPays":     soeRequired,
+		"TakerGets":     soeRequired,
+		"Expiration":    soeOptional,
+		"OfferSequence": soeOptional,
+	},
+	OFFER_CANCEL: {
+		"OfferSequence": soeRequired,
+	},
+	SET_DEPOSIT_PREAUTH: {
+		"Authorize":   soeOptional,
+		"Unauthorize": soeOptional,
+	},
+	TRUST_SET: {
+		"LimitAmount": soeRequired,
+		"QualityIn":   soeOptional,
+		"QualityOut":  soeOptional,
+	},
+	ACCOUNT_DELETE: {
+		"Destination":    soeRequired,
+		"DestinationTag": soeOptional,
+	},
+	NFTOKEN_MINT: {
+		"NFTokenTaxon": soeRequired,
+		"Issuer":       soeOptional,
+		"TransferFee":  soeOptional,
+		"URI":          soeOptional,
+	},
+	NFTOKEN_BURN: {
+		"NFTokenID": soeRequired,
+		"Owner":     soeOptional,
+	},
+	NFTOKEN_CREATE_OFFER: {
+		"NFTokenID":   soeRequired,
+		"Amount":      soeRequired,
+		"Owner":       soeOptional,
+		"Destination": soeOptional,
+		"Expiration":  soeOptional,
+	},
+	NFTOKEN_CANCEL_OFFER: {
+		"NFTokenOffers": soeRequired,
+	},
+	NFTOKEN_ACCEPT_OFFER: {
+		"NFTokenSellOffer": soeOptional,
+		"NFTokenBuyOffer":  soeOptional,
+		"NFTokenBrokerFee": soeOptional,
+	},
+	AMENDMENT: {
+		"Amendment": soeRequired,
+	},
+	SET_FEE: {
+		"BaseFee":           soeRequired,
+		"ReferenceFeeUnits": soeRequired,
+		"ReserveBase":       soeRequired,
+		"ReserveIncrement":  soeRequired,
+	},
 }
 
 var reverseEncodings map[string]enc
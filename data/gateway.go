@@ -0,0 +1,178 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Holding is an account's balance of a currency an issuer owes it
+type Holding struct {
+	Holder  Account
+	Balance Value
+	Frozen  bool // By the issuer
+}
+
+type HoldingSlice []Holding
+
+func (s HoldingSlice) Len() int      { return len(s) }
+func (s HoldingSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s HoldingSlice) Less(i, j int) bool {
+	if c := s[i].Balance.Compare(s[j].Balance); c != 0 {
+		return c > 0
+	}
+	return s[i].Holder.Less(s[j].Holder)
+}
+
+// Obligations are what an issuer owes in one currency. Balances held by
+// its hot wallets are its own and so are kept apart from the Total owed
+// to other holders.
+type Obligations struct {
+	Currency       Currency
+	Total          Value
+	Holders        int          // With a positive balance
+	Frozen         int          // Lines frozen by the issuer
+	Top            HoldingSlice // The largest holders, largest first
+	HotWalletTotal Value
+	HotWallets     HoldingSlice // Largest first
+}
+
+func (o Obligations) String() string {
+	return fmt.Sprintf("Currency: %s Total: %20s Holders: %6d Frozen: %6d Hot Wallets: %20s", o.Currency, o.Total, o.Holders, o.Frozen, o.HotWalletTotal)
+}
+
+type ObligationsSlice []Obligations
+
+func (s ObligationsSlice) Len() int           { return len(s) }
+func (s ObligationsSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s ObligationsSlice) Less(i, j int) bool { return s[i].Currency.Less(s[j].Currency) }
+
+// GatewayBalances totals the trust lines of an issuer, much as rippled's
+// gateway_balances does, from ledger entries such as those in a ledger's
+// account state or streamed by StreamLedgerData. Entries other than the
+// issuer's trust lines are ignored, so a whole ledger can be added.
+type GatewayBalances struct {
+	Issuer     Account
+	hotWallets map[Account]bool
+	top        int
+	holdings   map[Currency]HoldingSlice
+	hot        map[Currency]HoldingSlice
+	frozen     map[Currency]int
+}
+
+// NewGatewayBalances returns a report on what issuer owes, keeping
+// the top largest holders of each currency
+func NewGatewayBalances(issuer Account, hotWallets []Account, top int) (*GatewayBalances, error) {
+	if top < 0 {
+		return nil, fmt.Errorf("Negative number of top holders: %d", top)
+	}
+	g := &GatewayBalances{
+		Issuer:     issuer,
+		hotWallets: make(map[Account]bool),
+		top:        top,
+		holdings:   make(map[Currency]HoldingSlice),
+		hot:        make(map[Currency]HoldingSlice),
+		frozen:     make(map[Currency]int),
+	}
+	for _, account := range hotWallets {
+		g.hotWallets[account] = true
+	}
+	return g, nil
+}
+
+// Add adds an entry if it is one of the issuer's trust lines
+func (g *GatewayBalances) Add(le LedgerEntry) error {
+	line, ok := le.(*RippleState)
+	if !ok {
+		return nil
+	}
+	if line.LowLimit == nil || line.HighLimit == nil || line.Balance == nil {
+		return fmt.Errorf("Incomplete RippleState: %s", line.GetHash())
+	}
+	// A positive balance is owed to the low account
+	holder, balance, freeze := line.HighLimit.Issuer, line.Balance.Value.Negate(), LsLowFreeze
+	switch {
+	case line.HighLimit.Issuer.Equals(g.Issuer):
+		holder, balance, freeze = line.LowLimit.Issuer, line.Balance.Value, LsHighFreeze
+	case !line.LowLimit.Issuer.Equals(g.Issuer):
+		return nil
+	}
+	currency := line.Balance.Currency
+	frozen := line.Flags != nil && *line.Flags&freeze != 0
+	if frozen && !g.hotWallets[holder] {
+		g.frozen[currency]++
+	}
+	if balance.IsZero() || balance.IsNegative() {
+		// The issuer holds the other account's currency
+		return nil
+	}
+	holding := Holding{Holder: holder, Balance: *balance, Frozen: frozen}
+	if g.hotWallets[holder] {
+		g.hot[currency] = append(g.hot[currency], holding)
+	} else {
+		g.holdings[currency] = append(g.holdings[currency], holding)
+	}
+	return nil
+}
+
+// AddEntries adds each of the entries
+func (g *GatewayBalances) AddEntries(entries LedgerEntrySlice) error {
+	for _, le := range entries {
+		if err := g.Add(le); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Obligations returns the totals for each currency the issuer owes
+func (g *GatewayBalances) Obligations() (ObligationsSlice, error) {
+	currencies := make(map[Currency]bool)
+	for _, m := range []map[Currency]HoldingSlice{g.holdings, g.hot} {
+		for currency := range m {
+			currencies[currency] = true
+		}
+	}
+	for currency := range g.frozen {
+		currencies[currency] = true
+	}
+	var obligations ObligationsSlice
+	for currency := range currencies {
+		holdings, hot := g.holdings[currency], g.hot[currency]
+		sort.Sort(holdings)
+		sort.Sort(hot)
+		total, err := sumHoldings(holdings)
+		if err != nil {
+			return nil, err
+		}
+		hotTotal, err := sumHoldings(hot)
+		if err != nil {
+			return nil, err
+		}
+		top := holdings
+		if len(top) > g.top {
+			top = top[:g.top]
+		}
+		obligations = append(obligations, Obligations{
+			Currency:       currency,
+			Total:          *total,
+			Holders:        len(holdings),
+			Frozen:         g.frozen[currency],
+			Top:            append(HoldingSlice(nil), top...),
+			HotWalletTotal: *hotTotal,
+			HotWallets:     append(HoldingSlice(nil), hot...),
+		})
+	}
+	sort.Sort(obligations)
+	return obligations, nil
+}
+
+func sumHoldings(holdings HoldingSlice) (*Value, error) {
+	sum := zeroNonNative.Clone()
+	for _, h := range holdings {
+		var err error
+		if sum, err = sum.Add(h.Balance); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type GatewaySuite struct{}
+
+var _ = Suite(&GatewaySuite{})
+
+// gatewayLine returns the RippleState on which issuer owes holder balance
+func gatewayLine(c *C, holder Account, balance string, issuerFreeze bool) *RippleState {
+	amount := builderAmount(c, balance)
+	low, high, value := holder, amount.Issuer, amount.Value
+	freeze := LsHighFreeze
+	if high.Less(low) {
+		low, high, value, freeze = high, low, value.Negate(), LsLowFreeze
+	}
+	var flags LedgerEntryFlag
+	if issuerFreeze {
+		flags = freeze
+	}
+	return &RippleState{
+		leBase:    leBase{LedgerEntryType: RIPPLE_STATE},
+		Flags:     &flags,
+		LowLimit:  &Amount{Value: value.ZeroClone(), Currency: amount.Currency, Issuer: low},
+		HighLimit: &Amount{Value: value.ZeroClone(), Currency: amount.Currency, Issuer: high},
+		Balance:   &Amount{Value: value, Currency: amount.Currency},
+	}
+}
+
+func (s *GatewaySuite) TestGatewayBalances(c *C) {
+	gateway := "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX"
+	alice := builderAccount(c, "iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn")
+	bob := builderAccount(c, "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9")
+	hot := builderAccount(c, "iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf")
+
+	_, err := NewGatewayBalances(builderAccount(c, gateway), nil, -1)
+	c.Check(err, ErrorMatches, "Negative number of top holders: -1")
+	g, err := NewGatewayBalances(builderAccount(c, gateway), []Account{hot}, 1)
+	c.Assert(err, IsNil)
+	c.Assert(g.AddEntries(LedgerEntrySlice{
+		gatewayLine(c, alice, "100/USD/"+gateway, true),
+		gatewayLine(c, bob, "250/USD/"+gateway, false),
+		gatewayLine(c, hot, "1000/USD/"+gateway, false),
+		gatewayLine(c, bob, "5/EUR/"+gateway, false),
+		gatewayLine(c, hot, "0/EUR/"+gateway, true),
+		// Owed to the gateway by alice, and between others
+		gatewayLine(c, builderAccount(c, gateway), "7/USD/"+alice.String(), false),
+		gatewayLine(c, alice, "3/USD/"+bob.String(), false),
+		&AccountRoot{leBase: leBase{LedgerEntryType: ACCOUNT_ROOT}, Account: &alice},
+	}), IsNil)
+
+	obligations, err := g.Obligations()
+	c.Assert(err, IsNil)
+	c.Assert(obligations, HasLen, 2)
+	eur, usd := obligations[0], obligations[1]
+	c.Check(eur.Currency.String(), Equals, "EUR")
+	c.Check(eur.Total.String(), Equals, "5")
+	c.Check(eur.Holders, Equals, 1)
+	// Hot wallets are not counted as frozen holders
+	c.Check(eur.Frozen, Equals, 0)
+	c.Check(eur.HotWallets, HasLen, 0)
+
+	c.Check(usd.String(), Matches, "Currency: USD Total: +350 Holders: +2 Frozen: +1 Hot Wallets: +1000")
+	c.Assert(usd.Top, HasLen, 1)
+	c.Check(usd.Top[0].Holder, Equals, bob)
+	c.Check(usd.Top[0].Balance.String(), Equals, "250")
+	c.Assert(usd.HotWallets, HasLen, 1)
+	c.Check(usd.HotWallets[0].Holder, Equals, hot)
+}
@@ -174,6 +174,70 @@ func NewAccountFromAddress(s string) (*Account, error) {
 	return &account, nil
 }
 
+// TaggedAccount is an account with the destination tag and network
+// which an X-address may carry
+type TaggedAccount struct {
+	Account Account
+	Tag     *uint32
+	Test    bool
+}
+
+// NewTaggedAccount accepts a classic address, which has no tag, or an
+// X-address for either network
+func NewTaggedAccount(s string) (*TaggedAccount, error) {
+	if !crypto.IsXAddress(s) {
+		account, err := NewAccountFromAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		return &TaggedAccount{Account: *account}, nil
+	}
+	id, tag, test, err := crypto.DecodeXAddress(s)
+	if err != nil {
+		return nil, err
+	}
+	t := &TaggedAccount{Tag: tag, Test: test}
+	copy(t.Account[:], id)
+	return t, nil
+}
+
+// checkNetwork refuses an X-address for the test network unless test is set
+func (t *TaggedAccount) checkNetwork(test bool) error {
+	if t.Test && !test {
+		return fmt.Errorf("X-address is for the test network: %s", t.Account.XAddress(t.Tag, t.Test))
+	}
+	return nil
+}
+
+// fill sets the account and, when the address had one, the tag, which
+// must agree with any tag already set
+func (t *TaggedAccount) fill(account *Account, tag **uint32) error {
+	if err := t.checkNetwork(false); err != nil {
+		return err
+	}
+	*account = t.Account
+	if t.Tag == nil {
+		return nil
+	}
+	if *tag != nil && **tag != *t.Tag {
+		return fmt.Errorf("X-address tag %d conflicts with DestinationTag %d", *t.Tag, **tag)
+	}
+	*tag = t.Tag
+	return nil
+}
+
+func (t TaggedAccount) String() string {
+	b, _ := t.MarshalText()
+	return string(b)
+}
+
+// XAddress returns the X-address of the account with tag, which may be
+// nil, for the main network or, if test is set, the test network
+func (a Account) XAddress(tag *uint32, test bool) string {
+	address, _ := crypto.EncodeXAddress(a[:], tag, test)
+	return address
+}
+
 func (a Account) Hash() (crypto.Hash, error) {
 	return crypto.NewAccountId(a[:])
 }
@@ -3,6 +3,7 @@ package data
 import (
 	"bytes"
 	"crypto/sha512"
+	"encoding/binary"
 	"fmt"
 	"math"
 )
@@ -41,6 +42,14 @@ func LedgerIndex(le LedgerEntry) (*Hash256, error) {
 		return buildIndex([]interface{}{NS_FEE})
 	case *Amendments:
 		return buildIndex([]interface{}{NS_AMENDMENT})
+	case *Preauthorization:
+		return GetDepositPreauthIndex(*v.Account, *v.Authorize)
+	case *NFTokenOffer, *NFTokenPage:
+		// Neither entry stores the fields its index is derived from
+		if index := le.GetLedgerIndex(); index != nil {
+			return index, nil
+		}
+		return nil, fmt.Errorf("Missing index for %s", le.GetLedgerEntryType())
 	default:
 		return nil, fmt.Errorf("Unknown LedgerEntry")
 	}
@@ -61,6 +70,22 @@ func GetRippleStateIndex(a, b Account, c Currency) (*Hash256, error) {
 	return buildIndex([]interface{}{NS_RIPPLE_STATE, b.Bytes(), a.Bytes(), c.Bytes()})
 }
 
+func GetDepositPreauthIndex(owner, authorized Account) (*Hash256, error) {
+	return buildIndex([]interface{}{NS_DEPOSIT_PREAUTH, owner.Bytes(), authorized.Bytes()})
+}
+
+func GetNFTokenOfferIndex(owner Account, sequence uint32) (*Hash256, error) {
+	return buildIndex([]interface{}{NS_NFTOKEN_OFFER, owner.Bytes(), sequence})
+}
+
+func GetNFTokenBuyOffersIndex(id Hash256) (*Hash256, error) {
+	return buildIndex([]interface{}{NS_NFTOKEN_BUY_OFFERS, id.Bytes()})
+}
+
+func GetNFTokenSellOffersIndex(id Hash256) (*Hash256, error) {
+	return buildIndex([]interface{}{NS_NFTOKEN_SELL_OFFERS, id.Bytes()})
+}
+
 func GetDirectoryNodeIndex(root Hash256, index *NodeIndex) (*Hash256, error) {
 	if index == nil {
 		return &root, nil
@@ -72,9 +97,11 @@ func GetOwnerDirectoryIndex(account Account) (*Hash256, error) {
 	return buildIndex([]interface{}{NS_OWNER_DIRECTORY, account.Bytes()})
 }
 
+// GetBookIndex returns the base of a book's directories, which has
+// the quality of each directory added with GetQualityIndex
 func GetBookIndex(paysCurrency, getsCurrency Hash160, paysIssuer, getsIssuer Hash160) (*Hash256, error) {
 	//TODO: change types to Currency and Account
-	index, err := buildIndex([]interface{}{NS_BOOK_DIRECTORY, paysCurrency.Bytes(), getsCurrency.Bytes(), paysCurrency.Bytes(), getsCurrency.Bytes()})
+	index, err := buildIndex([]interface{}{NS_BOOK_DIRECTORY, paysCurrency.Bytes(), getsCurrency.Bytes(), paysIssuer.Bytes(), getsIssuer.Bytes()})
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +110,17 @@ func GetBookIndex(paysCurrency, getsCurrency Hash160, paysIssuer, getsIssuer Has
 	return index, nil
 }
 
+// GetQualityIndex returns the index of the book directory at a quality
+func GetQualityIndex(book Hash256, rate ExchangeRate) *Hash256 {
+	binary.BigEndian.PutUint64(book[24:], uint64(rate))
+	return &book
+}
+
+// GetQuality returns the quality of a book directory from its index
+func GetQuality(index Hash256) ExchangeRate {
+	return ExchangeRate(binary.BigEndian.Uint64(index[24:]))
+}
+
 func GetFeeIndex() (*Hash256, error) {
 	return buildIndex([]interface{}{NS_FEE})
 }
@@ -260,7 +260,7 @@ func (r *TransactionResult) UnmarshalText(b []byte) error {
 }
 
 func (l LedgerEntryType) MarshalText() ([]byte, error) {
-	return []byte(ledgerEntryNames[l]), nil
+	return []byte(l.String()), nil
 }
 
 func (l *LedgerEntryType) UnmarshalText(b []byte) error {
@@ -272,7 +272,7 @@ func (l *LedgerEntryType) UnmarshalText(b []byte) error {
 }
 
 func (t TransactionType) MarshalText() ([]byte, error) {
-	return []byte(txNames[t]), nil
+	return []byte(t.String()), nil
 }
 
 func (t *TransactionType) UnmarshalText(b []byte) error {
@@ -409,16 +409,72 @@ func (a Account) MarshalText() ([]byte, error) {
 	return address.MarshalText()
 }
 
-// Expects base58-encoded account id
+// Expects base58-encoded account id or a main network X-address without
+// a tag. A tag can only be kept by a TaggedAccount, or by the Destination
+// of a transaction with a DestinationTag.
 func (a *Account) UnmarshalText(b []byte) error {
-	account, err := NewAccountFromAddress(string(b))
+	var t TaggedAccount
+	if err := t.UnmarshalText(b); err != nil {
+		return err
+	}
+	if err := t.checkNetwork(false); err != nil {
+		return err
+	}
+	if t.Tag != nil {
+		return fmt.Errorf("X-address has a tag: %s", b)
+	}
+	copy(a[:], t.Account[:])
+	return nil
+}
+
+// Marshals to an X-address when there is a tag
+func (t TaggedAccount) MarshalText() ([]byte, error) {
+	if t.Tag == nil {
+		return t.Account.MarshalText()
+	}
+	return []byte(t.Account.XAddress(t.Tag, t.Test)), nil
+}
+
+// Expects base58-encoded account id or an X-address
+func (t *TaggedAccount) UnmarshalText(b []byte) error {
+	tagged, err := NewTaggedAccount(string(b))
 	if err != nil {
 		return err
 	}
-	copy(a[:], account[:])
+	*t = *tagged
 	return nil
 }
 
+type paymentJSON Payment
+
+// Accepts a main network X-address as the Destination, whose tag becomes
+// the DestinationTag
+func (p *Payment) UnmarshalJSON(b []byte) error {
+	extract := &struct {
+		*paymentJSON
+		Destination TaggedAccount
+	}{paymentJSON: (*paymentJSON)(p)}
+	if err := json.Unmarshal(b, extract); err != nil {
+		return err
+	}
+	return extract.Destination.fill(&p.Destination, &p.DestinationTag)
+}
+
+type accountDeleteJSON AccountDelete
+
+// Accepts a main network X-address as the Destination, whose tag becomes
+// the DestinationTag
+func (a *AccountDelete) UnmarshalJSON(b []byte) error {
+	extract := &struct {
+		*accountDeleteJSON
+		Destination TaggedAccount
+	}{accountDeleteJSON: (*accountDeleteJSON)(a)}
+	if err := json.Unmarshal(b, extract); err != nil {
+		return err
+	}
+	return extract.Destination.fill(&a.Destination, &a.DestinationTag)
+}
+
 func (r RegularKey) MarshalText() ([]byte, error) {
 	address, err := r.Hash()
 	if err != nil {
@@ -13,20 +13,24 @@ type leBase struct {
 
 type AccountRoot struct {
 	leBase
-	Flags         *LedgerEntryFlag `json:",omitempty"`
-	Account       *Account         `json:",omitempty"`
-	Sequence      *uint32          `json:",omitempty"`
-	Balance       *Value           `json:",omitempty"`
-	OwnerCount    *uint32          `json:",omitempty"`
-	AccountTxnID  *Hash256         `json:",omitempty"`
-	RegularKey    *RegularKey      `json:",omitempty"`
-	EmailHash     *Hash128         `json:",omitempty"`
-	WalletLocator *Hash256         `json:",omitempty"`
-	WalletSize    *uint32          `json:",omitempty"`
-	MessageKey    *VariableLength  `json:",omitempty"`
-	TransferRate  *uint32          `json:",omitempty"`
-	Domain        *VariableLength  `json:",omitempty"`
-	Signers       *VariableLength  `json:",omitempty"`
+	Flags                *LedgerEntryFlag `json:",omitempty"`
+	Account              *Account         `json:",omitempty"`
+	Sequence             *uint32          `json:",omitempty"`
+	Balance              *Value           `json:",omitempty"`
+	OwnerCount           *uint32          `json:",omitempty"`
+	AccountTxnID         *Hash256         `json:",omitempty"`
+	RegularKey           *RegularKey      `json:",omitempty"`
+	EmailHash            *Hash128         `json:",omitempty"`
+	WalletLocator        *Hash256         `json:",omitempty"`
+	WalletSize           *uint32          `json:",omitempty"`
+	MessageKey           *VariableLength  `json:",omitempty"`
+	TransferRate         *uint32          `json:",omitempty"`
+	Domain               *VariableLength  `json:",omitempty"`
+	Signers              *VariableLength  `json:",omitempty"`
+	NFTokenMinter        *Account         `json:",omitempty"`
+	MintedNFTokens       *uint32          `json:",omitempty"`
+	BurnedNFTokens       *uint32          `json:",omitempty"`
+	FirstNFTokenSequence *uint32          `json:",omitempty"`
 }
 
 type RippleState struct {
@@ -94,7 +98,35 @@ type FeeSettings struct {
 	ReserveIncrement  *uint32          `json:",omitempty"`
 }
 
-func (le *leBase) GetType() string                     { return ledgerEntryNames[le.LedgerEntryType] }
+type Preauthorization struct {
+	leBase
+	Flags     *LedgerEntryFlag `json:",omitempty"`
+	Account   *Account         `json:",omitempty"`
+	Authorize *Account         `json:",omitempty"`
+	OwnerNode *NodeIndex       `json:",omitempty"`
+}
+
+type NFTokenPage struct {
+	leBase
+	Flags           *LedgerEntryFlag `json:",omitempty"`
+	PreviousPageMin *Hash256         `json:",omitempty"`
+	NextPageMin     *Hash256         `json:",omitempty"`
+	NFTokens        NFTokens         `json:",omitempty"`
+}
+
+type NFTokenOffer struct {
+	leBase
+	Flags            *LedgerEntryFlag `json:",omitempty"`
+	Owner            *Account         `json:",omitempty"`
+	NFTokenID        *Hash256         `json:",omitempty"`
+	Amount           *Amount          `json:",omitempty"`
+	Expiration       *uint32          `json:",omitempty"`
+	Destination      *Account         `json:",omitempty"`
+	OwnerNode        *NodeIndex       `json:",omitempty"`
+	NFTokenOfferNode *NodeIndex       `json:",omitempty"`
+}
+
+func (le *leBase) GetType() string                     { return le.LedgerEntryType.String() }
 func (le *leBase) GetLedgerEntryType() LedgerEntryType { return le.LedgerEntryType }
 func (le *leBase) Prefix() HashPrefix                  { return HP_LEAF_NODE }
 func (le *leBase) NodeType() NodeType                  { return NT_ACCOUNT_NODE }
@@ -107,3 +139,12 @@ func (le *leBase) GetPreviousTxnId() *Hash256          { return le.PreviousTxnID
 func (o *Offer) Ratio() *Value {
 	return o.TakerPays.Ratio(*o.TakerGets)
 }
+
+// Quality returns the quality the offer was placed at, which is kept
+// in its BookDirectory even after partial fills have changed the ratio
+func (o *Offer) Quality() ExchangeRate {
+	if o.BookDirectory == nil {
+		return 0
+	}
+	return GetQuality(*o.BookDirectory)
+}
@@ -1,10 +1,152 @@
 package data
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
 type Memo struct {
 	Memo struct {
-		MemoType VariableLength
-		MemoData VariableLength
+		MemoType   VariableLength
+		MemoData   VariableLength
+		MemoFormat VariableLength `json:",omitempty"`
 	}
 }
 
 type Memos []Memo
+
+// MaxMemoSize is the largest serialized Memos field rippled accepts
+const MaxMemoSize = 1024
+
+// Memo formats set by the constructors
+const (
+	MemoFormatText   = "text/plain"
+	MemoFormatJSON   = "application/json"
+	MemoFormatBinary = "application/octet-stream"
+)
+
+// Characters allowed in MemoType and MemoFormat, those of URLs
+const memoCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~:/?#[]@!$&'()*+,;=%"
+
+func newMemo(typ, format string, data []byte) (*Memo, error) {
+	var memo Memo
+	memo.Memo.MemoType = VariableLength(typ)
+	memo.Memo.MemoFormat = VariableLength(format)
+	memo.Memo.MemoData = VariableLength(data)
+	if err := memo.Validate(); err != nil {
+		return nil, err
+	}
+	return &memo, nil
+}
+
+// NewTextMemo returns a memo containing UTF-8 text
+func NewTextMemo(typ, text string) (*Memo, error) {
+	if !utf8.ValidString(text) {
+		return nil, fmt.Errorf("Memo text is not valid UTF-8")
+	}
+	return newMemo(typ, MemoFormatText, []byte(text))
+}
+
+// NewJSONMemo returns a memo containing v encoded as JSON
+func NewJSONMemo(typ string, v interface{}) (*Memo, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return newMemo(typ, MemoFormatJSON, b)
+}
+
+// NewBinaryMemo returns a memo containing arbitrary bytes
+func NewBinaryMemo(typ string, b []byte) (*Memo, error) {
+	return newMemo(typ, MemoFormatBinary, b)
+}
+
+func (m Memo) Type() string   { return string(m.Memo.MemoType) }
+func (m Memo) Format() string { return string(m.Memo.MemoFormat) }
+
+// size returns the length of the serialized memo
+func (m Memo) size() int {
+	size := 2 // Memo and EndOfObject
+	for _, field := range []VariableLength{m.Memo.MemoType, m.Memo.MemoData, m.Memo.MemoFormat} {
+		if len(field) > 0 {
+			var b bytes.Buffer
+			writeVariableLength(&b, field)
+			size += 1 + b.Len()
+		}
+	}
+	return size
+}
+
+// Validate checks the memo would be accepted by rippled
+func (m Memo) Validate() error {
+	for _, field := range []struct {
+		name  string
+		value VariableLength
+	}{{"MemoType", m.Memo.MemoType}, {"MemoFormat", m.Memo.MemoFormat}} {
+		for _, c := range field.value {
+			if strings.IndexByte(memoCharacters, c) < 0 {
+				return fmt.Errorf("%s contains invalid character: %q", field.name, c)
+			}
+		}
+	}
+	if size := m.size(); size > MaxMemoSize {
+		return fmt.Errorf("Memo too large: %d bytes", size)
+	}
+	return nil
+}
+
+// Validate checks every memo and that together they fit in MaxMemoSize
+func (memos Memos) Validate() error {
+	// rippled counts the memos but not the Memos header or EndOfArray
+	size := 0
+	for i, memo := range memos {
+		if err := memo.Validate(); err != nil {
+			return fmt.Errorf("Memos[%d]: %s", i, err)
+		}
+		size += memo.size()
+	}
+	if size > MaxMemoSize {
+		return fmt.Errorf("Memos too large: %d bytes", size)
+	}
+	return nil
+}
+
+func (m Memo) isText() bool {
+	format := m.Format()
+	return strings.HasPrefix(format, "text/") || format == MemoFormatJSON || strings.HasSuffix(format, "+json")
+}
+
+// Text returns the memo's data as text, which requires a text or JSON format
+func (m Memo) Text() (string, error) {
+	if !m.isText() {
+		return "", fmt.Errorf("Memo format is not text: %q", m.Format())
+	}
+	if !utf8.Valid(m.Memo.MemoData) {
+		return "", fmt.Errorf("Memo text is not valid UTF-8")
+	}
+	return string(m.Memo.MemoData), nil
+}
+
+// DecodeJSON unmarshals the memo's data into v, which requires a JSON format
+func (m Memo) DecodeJSON(v interface{}) error {
+	if format := m.Format(); format != MemoFormatJSON && !strings.HasSuffix(format, "+json") {
+		return fmt.Errorf("Memo format is not JSON: %q", format)
+	}
+	return json.Unmarshal(m.Memo.MemoData, v)
+}
+
+// String returns the memo's type and its data, as text when the format
+// allows and otherwise in hex
+func (m Memo) String() string {
+	data, err := m.Text()
+	if err != nil {
+		data = m.Memo.MemoData.String()
+	}
+	if m.Format() == "" {
+		return fmt.Sprintf("%s: %s", m.Type(), data)
+	}
+	return fmt.Sprintf("%s (%s): %s", m.Type(), m.Format(), data)
+}
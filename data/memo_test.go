@@ -0,0 +1,73 @@
+package data
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type MemoSuite struct{}
+
+var _ = Suite(&MemoSuite{})
+
+func (s *MemoSuite) TestMemoConstructors(c *C) {
+	text, err := NewTextMemo("invoice", "Thanks for lunch ☺")
+	c.Assert(err, IsNil)
+	c.Check(text.Format(), Equals, MemoFormatText)
+	decoded, err := text.Text()
+	c.Assert(err, IsNil)
+	c.Check(decoded, Equals, "Thanks for lunch ☺")
+	c.Check(text.String(), Equals, "invoice (text/plain): Thanks for lunch ☺")
+	c.Check(text.DecodeJSON(nil), ErrorMatches, `Memo format is not JSON: "text/plain"`)
+
+	js, err := NewJSONMemo("order", map[string]int{"id": 42})
+	c.Assert(err, IsNil)
+	var order struct{ Id int }
+	c.Assert(js.DecodeJSON(&order), IsNil)
+	c.Check(order.Id, Equals, 42)
+	c.Check(js.String(), Equals, `order (application/json): {"id":42}`)
+
+	binary, err := NewBinaryMemo("blob", []byte{0xDE, 0xAD})
+	c.Assert(err, IsNil)
+	_, err = binary.Text()
+	c.Check(err, ErrorMatches, `Memo format is not text: "application/octet-stream"`)
+	c.Check(binary.String(), Equals, "blob (application/octet-stream): DEAD")
+
+	_, err = NewTextMemo("two words", "")
+	c.Check(err, ErrorMatches, `MemoType contains invalid character: ' '`)
+	_, err = NewTextMemo("big", strings.Repeat("x", MaxMemoSize))
+	c.Check(err, ErrorMatches, "Memo too large: .*")
+	_, err = NewTextMemo("bad", "\xff")
+	c.Check(err, ErrorMatches, "Memo text is not valid UTF-8")
+
+	half, err := NewTextMemo("half", strings.Repeat("x", MaxMemoSize/2))
+	c.Assert(err, IsNil)
+	c.Check(Memos{*half}.Validate(), IsNil)
+	c.Check(Memos{*half, *half}.Validate(), ErrorMatches, "Memos too large: .*")
+
+	// The 34 bytes around the data bring it to exactly MaxMemoSize
+	full, err := NewBinaryMemo("t", make([]byte, MaxMemoSize-34))
+	c.Assert(err, IsNil)
+	c.Check(Memos{*full}.Validate(), IsNil)
+	_, err = NewBinaryMemo("t", make([]byte, MaxMemoSize-33))
+	c.Check(err, ErrorMatches, "Memo too large: 1025 bytes")
+}
+
+func (s *MemoSuite) TestMemoFormatEncoding(c *C) {
+	memo, err := NewTextMemo("a", "b")
+	c.Assert(err, IsNil)
+	tx := &AccountSet{TxBase: TxBase{TransactionType: ACCOUNT_SET, Fee: *zeroNative.Clone()}}
+	tx.Memos = Memos{*memo}
+	_, raw, err := Raw(tx)
+	c.Assert(err, IsNil)
+	// Memos, Memo, MemoType, MemoData, MemoFormat, EndOfObject, EndOfArray
+	expected := []byte{0xF9, 0xEA, 0x7C, 0x01, 'a', 0x7D, 0x01, 'b', 0x7E, 0x0A}
+	expected = append(append(expected, MemoFormatText...), 0xE1, 0xF1)
+	c.Check(bytes.HasSuffix(raw, expected), Equals, true, Commentf("%X", raw))
+
+	decoded, err := ReadTransaction(bytes.NewReader(raw))
+	c.Assert(err, IsNil)
+	c.Assert(decoded.GetBase().Memos, HasLen, 1)
+	c.Check(decoded.GetBase().Memos[0].String(), Equals, "a (text/plain): b")
+}
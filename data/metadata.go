@@ -1,6 +1,7 @@
 package data
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 )
@@ -53,9 +54,10 @@ func (s TransactionSlice) Sort() { sort.Sort(s) }
 
 type TransactionWithMetaData struct {
 	Transaction
-	MetaData       MetaData `json:"meta"`
-	LedgerSequence uint32   `json:"ledger_index"`
-	Id             Hash256  `json:"-"`
+	MetaData       MetaData    `json:"meta"`
+	LedgerSequence uint32      `json:"ledger_index"`
+	Date           *RippleTime `json:"date,omitempty"` // Close time of the ledger, when known
+	Id             Hash256     `json:"-"`
 }
 
 func (t TransactionWithMetaData) GetType() string    { return t.Transaction.GetType() }
@@ -77,20 +79,73 @@ func (effect *NodeEffect) AffectedNode() (*AffectedNode, LedgerEntry, LedgerEntr
 		state           LedgerEntryState
 	)
 	switch {
-	case effect.CreatedNode != nil && effect.CreatedNode.NewFields != nil:
+	case effect.CreatedNode != nil:
 		node, final, state = effect.CreatedNode, effect.CreatedNode.NewFields, Created
-	case effect.DeletedNode != nil && effect.DeletedNode.FinalFields != nil:
+	case effect.DeletedNode != nil:
 		node, final, state = effect.DeletedNode, effect.DeletedNode.FinalFields, Deleted
-	case effect.ModifiedNode != nil && effect.ModifiedNode.FinalFields != nil:
+	case effect.ModifiedNode != nil:
 		node, final, state = effect.ModifiedNode, effect.ModifiedNode.FinalFields, Modified
-	case effect.ModifiedNode != nil && effect.ModifiedNode.FinalFields == nil:
-		node, final, state = effect.ModifiedNode, LedgerEntryFactory[effect.ModifiedNode.LedgerEntryType](), Modified
 	default:
 		panic(fmt.Sprintf("Unknown LedgerEntryState: %+v", effect))
 	}
+	if final == nil {
+		factory := ledgerEntryFactory(node.LedgerEntryType)
+		if factory == nil {
+			panic(fmt.Sprintf("Unknown LedgerEntryType: %+v", effect))
+		}
+		final = factory()
+	}
 	previous = node.PreviousFields
 	if previous == nil {
-		previous = LedgerEntryFactory[final.GetLedgerEntryType()]()
+		previous = ledgerEntryFactory(final.GetLedgerEntryType())()
 	}
 	return node, final, previous, state
 }
+
+// ErrDeliveredAmountUnknown is returned by DeliveredAmount when the
+// metadata does not show how much reached the destination
+var ErrDeliveredAmountUnknown = errors.New("Delivered amount cannot be determined")
+
+// DeliveredAmount returns the amount a Payment actually delivered to its
+// destination, which for a partial payment can be less than Amount.
+// The DeliveredAmount in the metadata is used when present, otherwise
+// the amount is reconstructed from the destination's balance changes.
+func (txm *TransactionWithMetaData) DeliveredAmount() (*Amount, error) {
+	payment, ok := txm.Transaction.(*Payment)
+	if !ok {
+		return nil, fmt.Errorf("Not a Payment: %s", txm.GetType())
+	}
+	switch {
+	case !txm.MetaData.TransactionResult.Success():
+		return payment.Amount.ZeroClone(), nil
+	case txm.MetaData.DeliveredAmount != nil:
+		return txm.MetaData.DeliveredAmount, nil
+	case payment.Flags == nil || *payment.Flags&TxPartialPayment == 0:
+		return &payment.Amount, nil
+	}
+	balances, err := txm.Balances()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		delivered = payment.Amount.ZeroClone()
+		anyIssuer = payment.Amount.Issuer.Equals(payment.Destination)
+		found     bool
+	)
+	for _, balance := range balances {
+		switch {
+		case balance.Fee || !balance.Account.Equals(payment.Destination):
+		case !balance.Currency.Equals(payment.Amount.Currency):
+		case !payment.Amount.IsNative() && !anyIssuer && !balance.Counterparty.Equals(payment.Amount.Issuer):
+		default:
+			if delivered.Value, err = delivered.Value.Add(balance.Change); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+	}
+	if !found || delivered.IsNegative() || delivered.IsZero() {
+		return nil, ErrDeliveredAmountUnknown
+	}
+	return delivered, nil
+}
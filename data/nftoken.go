@@ -0,0 +1,141 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+type NFToken struct {
+	NFToken struct {
+		NFTokenID Hash256
+		URI       *VariableLength `json:",omitempty"`
+	}
+}
+
+type NFTokens []NFToken
+
+// NFTokenInfo is the decoded form of an NFTokenID
+//
+//	Flags(2) TransferFee(2) Issuer(20) Taxon(4) Serial(4)
+//
+// The taxon is stored scrambled with the serial so that tokens
+// sharing a taxon are not clustered together in NFTokenPages.
+type NFTokenInfo struct {
+	Flags       uint16
+	TransferFee uint16
+	Issuer      Account
+	Taxon       uint32
+	Serial      uint32
+}
+
+func NewNFTokenInfo(id Hash256) *NFTokenInfo {
+	info := &NFTokenInfo{
+		Flags:       binary.BigEndian.Uint16(id[0:2]),
+		TransferFee: binary.BigEndian.Uint16(id[2:4]),
+		Serial:      binary.BigEndian.Uint32(id[28:32]),
+	}
+	copy(info.Issuer[:], id[4:24])
+	info.Taxon = binary.BigEndian.Uint32(id[24:28]) ^ (384160001*info.Serial + 2459)
+	return info
+}
+
+func (info *NFTokenInfo) Burnable() bool     { return info.Flags&uint16(TxBurnable) > 0 }
+func (info *NFTokenInfo) OnlyXRP() bool      { return info.Flags&uint16(TxOnlyXRP) > 0 }
+func (info *NFTokenInfo) TrustLine() bool    { return info.Flags&uint16(TxTrustLine) > 0 }
+func (info *NFTokenInfo) Transferable() bool { return info.Flags&uint16(TxTransferable) > 0 }
+
+// NFTokenChange records a token entering or leaving an account's NFTokenPages
+type NFTokenChange struct {
+	Account   Account
+	NFTokenID Hash256
+}
+
+type NFTokenChangeSlice []NFTokenChange
+
+func (s NFTokenChangeSlice) Len() int      { return len(s) }
+func (s NFTokenChangeSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s NFTokenChangeSlice) Less(i, j int) bool {
+	if c := bytes.Compare(s[i].Account[:], s[j].Account[:]); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(s[i].NFTokenID[:], s[j].NFTokenID[:]) < 0
+}
+
+// nftokenChanges compares the tokens held in every affected NFTokenPage
+// before and after the transaction. The owner of a page is the first 20
+// bytes of its index.
+func (txm *TransactionWithMetaData) nftokenChanges() (added, removed NFTokenChangeSlice) {
+	before := make(map[NFTokenChange]bool)
+	after := make(map[NFTokenChange]bool)
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, final, previous, state := effect.AffectedNode()
+		if node.LedgerEntryType != NFTOKEN_PAGE || node.LedgerIndex == nil {
+			continue
+		}
+		var owner Account
+		copy(owner[:], node.LedgerIndex[:20])
+		current, ok := final.(*NFTokenPage)
+		if !ok {
+			continue
+		}
+		prior, next := current.NFTokens, current.NFTokens
+		switch state {
+		case Created:
+			prior = nil
+		case Deleted:
+			next = nil
+		}
+		if old, ok := previous.(*NFTokenPage); ok && old.NFTokens != nil && state != Created {
+			prior = old.NFTokens
+		}
+		for _, token := range prior {
+			before[NFTokenChange{owner, token.NFToken.NFTokenID}] = true
+		}
+		for _, token := range next {
+			after[NFTokenChange{owner, token.NFToken.NFTokenID}] = true
+		}
+	}
+	for change := range after {
+		if !before[change] {
+			added = append(added, change)
+		}
+	}
+	for change := range before {
+		if !after[change] {
+			removed = append(removed, change)
+		}
+	}
+	sort.Sort(added)
+	sort.Sort(removed)
+	return added, removed
+}
+
+// MintedNFTokens returns the tokens created by an NFTokenMint
+func (txm *TransactionWithMetaData) MintedNFTokens() NFTokenChangeSlice {
+	if txm.GetTransactionType() != NFTOKEN_MINT {
+		return nil
+	}
+	added, _ := txm.nftokenChanges()
+	return added
+}
+
+// BurnedNFTokens returns the tokens destroyed by an NFTokenBurn
+func (txm *TransactionWithMetaData) BurnedNFTokens() NFTokenChangeSlice {
+	if txm.GetTransactionType() != NFTOKEN_BURN {
+		return nil
+	}
+	_, removed := txm.nftokenChanges()
+	return removed
+}
+
+// TransferredNFTokens returns the tokens which changed hands in an
+// NFTokenAcceptOffer, as the removal from the seller and the addition
+// to the buyer
+func (txm *TransactionWithMetaData) TransferredNFTokens() (from, to NFTokenChangeSlice) {
+	if txm.GetTransactionType() != NFTOKEN_ACCEPT_OFFER {
+		return nil, nil
+	}
+	added, removed := txm.nftokenChanges()
+	return removed, added
+}
@@ -0,0 +1,75 @@
+package data
+
+import "fmt"
+
+// Issue is a currency together with its issuer, which is zero for ICC
+type Issue struct {
+	Currency Currency
+	Issuer   Account
+}
+
+func (a Amount) Issue() Issue {
+	if a.IsNative() {
+		return Issue{}
+	}
+	return Issue{a.Currency, a.Issuer}
+}
+
+func (i Issue) IsNative() bool {
+	return i.Currency.IsNative()
+}
+
+func (i Issue) Equals(other Issue) bool {
+	return i == other
+}
+
+func (i Issue) Less(other Issue) bool {
+	if !i.Currency.Equals(other.Currency) {
+		return i.Currency.Less(other.Currency)
+	}
+	return i.Issuer.Less(other.Issuer)
+}
+
+func (i Issue) String() string {
+	if i.IsNative() {
+		return "ICC"
+	}
+	return i.Currency.String() + "/" + i.Issuer.String()
+}
+
+// CurrencyPair orients a market. Prices are quoted as the amount of
+// Counter given for one unit of Base.
+type CurrencyPair struct {
+	Base    Issue
+	Counter Issue
+}
+
+// NewCurrencyPair returns the pair of a and b in its usual orientation,
+// which quotes prices in ICC when either side is native and otherwise
+// takes the lesser currency, and then issuer, as the Base
+func NewCurrencyPair(a, b Issue) CurrencyPair {
+	if a.IsNative() || (!b.IsNative() && b.Less(a)) {
+		a, b = b, a
+	}
+	return CurrencyPair{a, b}
+}
+
+func (p CurrencyPair) Inverse() CurrencyPair {
+	return CurrencyPair{p.Counter, p.Base}
+}
+
+// Matches reports whether other is the same pair in either orientation
+func (p CurrencyPair) Matches(other CurrencyPair) bool {
+	return p == other || p == other.Inverse()
+}
+
+func (p CurrencyPair) Less(other CurrencyPair) bool {
+	if !p.Base.Equals(other.Base) {
+		return p.Base.Less(other.Base)
+	}
+	return p.Counter.Less(other.Counter)
+}
+
+func (p CurrencyPair) String() string {
+	return fmt.Sprintf("%s:%s", p.Base, p.Counter)
+}
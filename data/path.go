@@ -74,6 +74,21 @@ func NewPath(s string) (Path, error) {
 	return p, nil
 }
 
+// AddAccount returns the path with a hop rippling through account
+func (p Path) AddAccount(account Account) Path {
+	return append(p, pathElem{Account: &account})
+}
+
+// AddIssue returns the path with a hop through the order book to issue,
+// which has no issuer when it is ICC
+func (p Path) AddIssue(issue Issue) Path {
+	elem := pathElem{Currency: &issue.Currency}
+	if !issue.IsNative() {
+		elem.Issuer = &issue.Issuer
+	}
+	return append(p, elem)
+}
+
 // PathSet represents a collection of possible paths that a transaction may use.
 type PathSet []Path
 
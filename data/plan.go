@@ -0,0 +1,99 @@
+package data
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A structPlan holds everything the encoder and decoder need to know
+// about a struct type which does not depend on the value being coded,
+// so that the reflection needed to work it out happens once per type.
+type structPlan struct {
+	fields  []plannedField
+	indexes map[string][]int // FieldByName results, including misses
+	mu      sync.RWMutex
+}
+
+type plannedField struct {
+	index    int
+	encoding enc
+	topOnly  bool // Omitted from the nested Fields of metadata
+}
+
+var plans = struct {
+	sync.RWMutex
+	m map[reflect.Type]*structPlan
+}{m: make(map[reflect.Type]*structPlan)}
+
+// planFor returns the cached plan for a struct type, creating it if needed
+func planFor(typ reflect.Type) *structPlan {
+	plans.RLock()
+	plan, ok := plans.m[typ]
+	plans.RUnlock()
+	if ok {
+		return plan
+	}
+	plan = newStructPlan(typ)
+	plans.Lock()
+	if existing, ok := plans.m[typ]; ok {
+		plan = existing
+	} else {
+		plans.m[typ] = plan
+	}
+	plans.Unlock()
+	return plan
+}
+
+// newStructPlan lists the fields of typ which are encoded. The fields of
+// an embedded struct, such as the leBase of every ledger entry, are
+// planned as one field and encoded in their turn, so that LedgerEntryType
+// and the PreviousTxn fields are part of an entry's hash. Its LedgerIndex
+// is not: the index is hashed after the fields of a state tree leaf.
+func newStructPlan(typ reflect.Type) *structPlan {
+	plan := &structPlan{indexes: make(map[string][]int)}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		// Unexported fields can't be interfaced, but the exported fields
+		// of an embedded one such as leBase can. A ledger entry's index
+		// follows its fields rather than being one of them.
+		if f.Name == "Hash" || f.Name == "Id" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		if f.Name == "LedgerIndex" && typ.Name() == "leBase" {
+			continue
+		}
+		plan.fields = append(plan.fields, plannedField{
+			index:    i,
+			encoding: reverseEncodings[f.Name],
+			topOnly:  f.Name == "LedgerEntryType" && typ.Name() == "leBase",
+		})
+	}
+	return plan
+}
+
+// resetPlans discards every plan, as they depend on the encoding tables
+func resetPlans() {
+	plans.Lock()
+	plans.m = make(map[reflect.Type]*structPlan)
+	plans.Unlock()
+}
+
+// fieldByName is a cached equivalent of v.FieldByName for a struct value
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	plan := planFor(v.Type())
+	plan.mu.RLock()
+	index, ok := plan.indexes[name]
+	plan.mu.RUnlock()
+	if !ok {
+		if f, found := v.Type().FieldByName(name); found {
+			index = f.Index
+		}
+		plan.mu.Lock()
+		plan.indexes[name] = index
+		plan.mu.Unlock()
+	}
+	if index == nil {
+		return reflect.Value{}
+	}
+	return v.FieldByIndex(index)
+}
@@ -0,0 +1,92 @@
+package data
+
+import "fmt"
+
+// Reserve is the ICC an account must hold and so cannot send. Every
+// account holds the Base and a further Increment for each object it
+// owns, such as a trust line or an offer.
+type Reserve struct {
+	Base      Value
+	Increment Value
+}
+
+// NewReserve returns the Reserve for a base and increment in drips
+func NewReserve(base, increment uint64) (*Reserve, error) {
+	b, err := NewNativeValue(int64(base))
+	if err != nil {
+		return nil, err
+	}
+	i, err := NewNativeValue(int64(increment))
+	if err != nil {
+		return nil, err
+	}
+	return &Reserve{Base: *b, Increment: *i}, nil
+}
+
+// Reserve returns the reserve set by the FeeSettings
+func (f *FeeSettings) Reserve() (*Reserve, error) {
+	if f.ReserveBase == nil || f.ReserveIncrement == nil {
+		return nil, fmt.Errorf("FeeSettings has no reserve")
+	}
+	return NewReserve(uint64(*f.ReserveBase), uint64(*f.ReserveIncrement))
+}
+
+// Required returns the reserve of an account which owns objects
+func (r Reserve) Required(objects uint32) (*Value, error) {
+	count, err := NewNativeValue(int64(objects))
+	if err != nil {
+		return nil, err
+	}
+	owner, err := r.Increment.Multiply(*count)
+	if err != nil {
+		return nil, err
+	}
+	return r.Base.Add(*owner)
+}
+
+func (r Reserve) String() string {
+	return fmt.Sprintf("%s + %s per object", r.Base, r.Increment)
+}
+
+func (a *AccountRoot) ownerCount() uint32 {
+	if a.OwnerCount == nil {
+		return 0
+	}
+	return *a.OwnerCount
+}
+
+// Reserve returns the ICC the account must hold for itself and the
+// objects it owns
+func (a *AccountRoot) Reserve(r Reserve) (*Value, error) {
+	return r.Required(a.ownerCount())
+}
+
+// Spendable returns the ICC the account can send, which is its balance
+// less its reserve, or zero when the balance does not cover the reserve.
+// Fees can be paid out of the reserve and so are not deducted.
+func (a *AccountRoot) Spendable(r Reserve) (*Value, error) {
+	if a.Balance == nil {
+		return nil, fmt.Errorf("AccountRoot has no balance")
+	}
+	reserve, err := a.Reserve(r)
+	if err != nil {
+		return nil, err
+	}
+	if a.Balance.Less(*reserve) {
+		return zeroNative.Clone(), nil
+	}
+	return a.Balance.Subtract(*reserve)
+}
+
+// CanAfford reports whether the account holds the reserve for objects
+// more owned objects, such as the trust lines or offers it is creating
+func (a *AccountRoot) CanAfford(r Reserve, objects uint32) (bool, error) {
+	if a.Balance == nil {
+		return false, fmt.Errorf("AccountRoot has no balance")
+	}
+	reserve, err := r.Required(a.ownerCount() + objects)
+	if err != nil {
+		return false, err
+	}
+	return !a.Balance.Less(*reserve), nil
+}
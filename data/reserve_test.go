@@ -0,0 +1,47 @@
+package data
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type ReserveSuite struct{}
+
+var _ = Suite(&ReserveSuite{})
+
+func (s *ReserveSuite) TestReserve(c *C) {
+	base, increment := uint32(20000000), uint32(5000000)
+	fees := &FeeSettings{ReserveBase: &base, ReserveIncrement: &increment}
+	reserve, err := fees.Reserve()
+	c.Assert(err, IsNil)
+	c.Check(reserve.String(), Equals, "20 + 5 per object")
+	required, err := reserve.Required(3)
+	c.Assert(err, IsNil)
+	c.Check(required.String(), Equals, "35")
+
+	_, err = (&FeeSettings{}).Reserve()
+	c.Check(err, ErrorMatches, "FeeSettings has no reserve")
+
+	balance, err := NewValue("41.0", true)
+	c.Assert(err, IsNil)
+	owned := uint32(3)
+	account := &AccountRoot{Balance: balance, OwnerCount: &owned}
+	spendable, err := account.Spendable(*reserve)
+	c.Assert(err, IsNil)
+	c.Check(spendable.String(), Equals, "6")
+	c.Check(spendable.IsNative(), Equals, true)
+
+	for objects, affordable := range []bool{true, true, false} {
+		ok, err := account.CanAfford(*reserve, uint32(objects))
+		c.Assert(err, IsNil)
+		c.Check(ok, Equals, affordable, Commentf("%d objects", objects))
+	}
+
+	// An account below its reserve can spend nothing
+	owned = 10
+	spendable, err = account.Spendable(*reserve)
+	c.Assert(err, IsNil)
+	c.Check(spendable.IsZero(), Equals, true)
+
+	_, err = (&AccountRoot{}).Spendable(*reserve)
+	c.Check(err, ErrorMatches, "AccountRoot has no balance")
+}
@@ -54,6 +54,14 @@ const (
 	tecNEED_MASTER_KEY
 	tecDST_TAG_NEEDED
 	tecINTERNAL
+	tecOVERSIZE
+	tecCRYPTOCONDITION_ERROR
+	tecINVARIANT_FAILED
+	tecEXPIRED
+	tecDUPLICATE
+	tecKILLED
+	tecHAS_OBLIGATIONS
+	tecTOO_SOON
 )
 
 const (
@@ -197,6 +205,14 @@ var resultNames = map[TransactionResult]struct {
 	tecNEED_MASTER_KEY:        {"tecNEED_MASTER_KEY", "The operation requires the use of the Master Key."},
 	tecDST_TAG_NEEDED:         {"tecDST_TAG_NEEDED", "A destination tag is required."},
 	tecINTERNAL:               {"tecINTERNAL", "An internal error has occurred during processing."},
+	tecOVERSIZE:               {"tecOVERSIZE", "Object exceeded serialization limits."},
+	tecCRYPTOCONDITION_ERROR:  {"tecCRYPTOCONDITION_ERROR", "Malformed, invalid, or mismatched conditional or fulfillment."},
+	tecINVARIANT_FAILED:       {"tecINVARIANT_FAILED", "One or more invariants for the transaction were not satisfied."},
+	tecEXPIRED:                {"tecEXPIRED", "Expiration time is passed."},
+	tecDUPLICATE:              {"tecDUPLICATE", "Ledger object already exists."},
+	tecKILLED:                 {"tecKILLED", "FillOrKill offer killed."},
+	tecHAS_OBLIGATIONS:        {"tecHAS_OBLIGATIONS", "The account cannot be deleted since it has obligations."},
+	tecTOO_SOON:               {"tecTOO_SOON", "It is too early to attempt the requested operation. Please wait."},
 	tefFAILURE:                {"tefFAILURE", "Failed to apply."},
 	tefALREADY:                {"tefALREADY", "The exact transaction was already in this ledger."},
 	tefBAD_ADD_AUTH:           {"tefBAD_ADD_AUTH", "Not authorized to add account."},
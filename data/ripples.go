@@ -5,22 +5,88 @@ import (
 	"sort"
 )
 
-// Trade
+// TradeSide says whether the taker bought or sold the Base of a trade's pair
+type TradeSide uint8
+
+const (
+	TakerBuy TradeSide = iota
+	TakerSell
+)
+
+func (s TradeSide) String() string {
+	if s == TakerBuy {
+		return "Buy"
+	}
+	return "Sell"
+}
+
+// Trade is an exchange between the account which sent a transaction,
+// the taker, and the owner of an offer it crossed, the maker. Paid is
+// what the taker paid the maker and Got what it received in return.
 type Trade struct {
-	Buyer  Account
-	Seller Account
-	Paid   Amount
-	Got    Amount
+	Taker    Account
+	Maker    Account
+	Sequence uint32 // Of the maker's offer
+	Paid     Amount
+	Got      Amount
+	Pair     CurrencyPair
+	Side     TradeSide
+}
+
+func newTrade(taker Account, offer OfferChange) Trade {
+	trade := Trade{
+		Taker:    taker,
+		Maker:    offer.Account,
+		Sequence: offer.Sequence,
+		Paid:     *offer.Paid,
+		Got:      *offer.Got,
+		Pair:     NewCurrencyPair(offer.Got.Issue(), offer.Paid.Issue()),
+	}
+	if !trade.Pair.Base.Equals(trade.Got.Issue()) {
+		trade.Side = TakerSell
+	}
+	return trade
 }
 
+// Base returns the amount of the pair's Base exchanged
+func (t Trade) Base() Amount {
+	if t.Side == TakerBuy {
+		return t.Got
+	}
+	return t.Paid
+}
+
+// Counter returns the amount of the pair's Counter exchanged
+func (t Trade) Counter() Amount {
+	if t.Side == TakerBuy {
+		return t.Paid
+	}
+	return t.Got
+}
+
+// Price returns the price of the trade in the orientation of its Pair
+// or nil if it cannot be calculated
 func (t Trade) Price() *Value {
-	price, err := t.Paid.Value.Ratio(*t.Got.Value)
+	price, err := t.Counter().Value.Ratio(*t.Base().Value)
 	if err != nil {
 		return nil
 	}
 	return price
 }
 
+// PriceIn returns the price of the trade as the amount of the pair's
+// Counter for one unit of its Base
+func (t Trade) PriceIn(pair CurrencyPair) (*Value, error) {
+	switch pair {
+	case t.Pair:
+		return t.Counter().Value.Ratio(*t.Base().Value)
+	case t.Pair.Inverse():
+		return t.Base().Value.Ratio(*t.Counter().Value)
+	default:
+		return nil, fmt.Errorf("Trade in %s has no price in %s", t.Pair, pair)
+	}
+}
+
 // Transfer is a directional representation of a RippleState or AccountRoot balance change.
 // Payments and OfferCreates lead to the creation of zero or more Transfers.
 //
@@ -43,19 +109,31 @@ type Transfer struct {
 	QualityOut         *Value // Applies to IOU -> IOU transfers
 }
 
+// Balance is a change to an account's holdings of a currency. IOU
+// balances are from the point of view of Account, with Counterparty
+// being the other side of the trust line. The fee destroyed by a
+// transaction is reported separately from its account's other changes.
 type Balance struct {
-	Account  Account
-	Balance  Value
-	Change   Value
-	Currency Currency
+	Account      Account
+	Balance      Value
+	Change       Value
+	Currency     Currency
+	Counterparty Account // Zero for ICC
+	Fee          bool    // Change is the fee destroyed by the transaction
 }
 
-// func (t Trade) String() string {
-// 	return fmt.Sprintf("%-34s=>%-34s %18s@%18s", t.Currency, t.Issuer, t.Seller, t.Buyer, t.Bought.Value, t.Price())
-// }
+func (t Trade) String() string {
+	return fmt.Sprintf("%-4s %s Taker: %-34s Maker: %-34s %8d Paid: %s Got: %s Price: %s", t.Side, t.Pair, t.Taker, t.Maker, t.Sequence, t.Paid, t.Got, t.Price())
+}
 
 func (b Balance) String() string {
-	return fmt.Sprintf("Account: %-34s  Currency: %s Balance: %20s Change: %20s", b.Account, b.Currency, b.Balance, b.Change)
+	if b.Fee {
+		return fmt.Sprintf("Account: %-34s  Currency: %s Balance: %20s Fee: %20s", b.Account, b.Currency, b.Balance, b.Change.Negate())
+	}
+	if b.Currency.IsNative() {
+		return fmt.Sprintf("Account: %-34s  Currency: %s Balance: %20s Change: %20s", b.Account, b.Currency, b.Balance, b.Change)
+	}
+	return fmt.Sprintf("Account: %-34s  Currency: %s Balance: %20s Change: %20s Counterparty: %-34s", b.Account, b.Currency, b.Balance, b.Change, b.Counterparty)
 }
 
 type TradeSlice []Trade
@@ -63,7 +141,12 @@ type BalanceSlice []Balance
 
 func (s TradeSlice) Len() int           { return len(s) }
 func (s TradeSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s TradeSlice) Less(i, j int) bool { return s[i].Price().Less(*s[j].Price()) }
+func (s TradeSlice) Less(i, j int) bool {
+	if s[i].Pair != s[j].Pair {
+		return s[i].Pair.Less(s[j].Pair)
+	}
+	return s[i].Price().Less(*s[j].Price())
+}
 
 func (s BalanceSlice) Len() int      { return len(s) }
 func (s BalanceSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
@@ -78,193 +161,155 @@ func (s BalanceSlice) Less(i, j int) bool {
 	}
 }
 
-func (s *TradeSlice) Add(buyer, seller *Account, paid, got *Amount) {
-	*s = append(*s, Trade{*buyer, *seller, *paid, *got})
-}
-
+// Sum returns the total bought in the trades, which must all share the
+// same currency and issuer
 func (s TradeSlice) Sum() (*Amount, error) {
 	if len(s) == 0 {
 		return nil, nil
 	}
-	return nil, nil
-	// sum, err := NewAmount(fmt.Sprintf("0/%s/%s", s[0].Currency, s[0].Issuer))
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// for _, trade := range s {
-	// 	if sum.Value, err = sum.Value.Add(trade.Amount); err != nil {
-	// 		return nil, err
-	// 	}
-	// }
-	// return sum, nil
+	sum := s[0].Got.Clone()
+	for _, trade := range s[1:] {
+		if !trade.Got.Currency.Equals(sum.Currency) || !trade.Got.Issuer.Equals(sum.Issuer) {
+			return nil, fmt.Errorf("Cannot sum trades in %s/%s and %s/%s", sum.Currency, sum.Issuer, trade.Got.Currency, trade.Got.Issuer)
+		}
+		var err error
+		if sum, err = sum.Add(&trade.Got); err != nil {
+			return nil, err
+		}
+	}
+	return sum, nil
 }
 
-func (s *BalanceSlice) Add(account *Account, balance, change *Value, currency *Currency) {
-	*s = append(*s, Balance{*account, *balance, *change, *currency})
+func (s *BalanceSlice) Add(account, counterparty *Account, balance, change *Value, currency *Currency) {
+	*s = append(*s, Balance{
+		Account:      *account,
+		Balance:      *balance,
+		Change:       *change,
+		Currency:     *currency,
+		Counterparty: *counterparty,
+	})
 }
 
+// Trades returns a Trade for every offer the transaction crossed,
+// ordered by pair and then price
 func (txm *TransactionWithMetaData) Trades() (TradeSlice, error) {
 	if txm.GetTransactionType() != OFFER_CREATE && txm.GetTransactionType() != PAYMENT {
 		return nil, nil
 	}
+	offers, err := txm.OfferChanges()
+	if err != nil {
+		return nil, err
+	}
 	var (
 		trades  TradeSlice
 		account = txm.Transaction.GetBase().Account
 	)
-	for _, node := range txm.MetaData.AffectedNodes {
-		var reason = ""
-		switch {
-		case node.CreatedNode != nil && node.CreatedNode.LedgerEntryType == OFFER:
-			// No actual side effect
-		case node.DeletedNode != nil && node.DeletedNode.LedgerEntryType == OFFER:
-			// An OfferCreate specifying previous OfferSequence has no side effect
-			if node.DeletedNode.PreviousFields == nil {
-				// reason = "No side effect"
-				break
-			}
-			// Fully consumed offer
-			var (
-				previous = node.DeletedNode.PreviousFields.(*Offer)
-				final    = node.DeletedNode.FinalFields.(*Offer)
-			)
-			if previous.TakerPays == nil {
-				reason = "Deleted Offer PreviousFields missing TakerPays"
-				break
-			}
-			if final.TakerPays == nil {
-				reason = "Deleted Offer FinalFields missing TakerPays"
-				break
-			}
-			if previous.TakerGets == nil {
-				reason = "Deleted Offer PreviousFields missing TakerGets"
-				break
-			}
-			if final.TakerGets == nil {
-				reason = "Deleted Offer FinalFields missing TakerGets"
-				break
-			}
-			trades.Add(&account, final.Account, previous.TakerPays, previous.TakerGets)
-		case node.ModifiedNode != nil && node.ModifiedNode.LedgerEntryType == OFFER:
-			// No change?
-			if node.ModifiedNode.PreviousFields == nil {
-				reason = "no change"
-				break
-			}
-			// Partially consumed offer
-			var (
-				previous = node.ModifiedNode.PreviousFields.(*Offer)
-				current  = node.ModifiedNode.FinalFields.(*Offer)
-			)
-			if previous.TakerPays == nil {
-				reason = "Modified Offer PreviousFields missing TakerPays"
-				break
-			}
-			if current.TakerPays == nil {
-				reason = "Modified Offer FinalFields missing TakerPays"
-				break
-			}
-			paid, err := previous.TakerPays.Subtract(current.TakerPays)
-			if err != nil {
-				return nil, err
-			}
-			if previous.TakerGets == nil {
-				reason = "Modified Offer PreviousFields missing TakerGets"
-				break
-			}
-			if current.TakerGets == nil {
-				reason = "Modified Offer FinalFields missing TakerGets"
-				break
-			}
-			got, err := previous.TakerGets.Subtract(current.TakerGets)
-			if err != nil {
-				return nil, err
-			}
-			trades.Add(&account, current.Account, paid, got)
-		}
-		if reason != "" {
-			fmt.Println(txm.LedgerSequence, txm.GetHash().String(), reason)
+	for _, offer := range offers {
+		// Offers removed as unfunded can give up dust for nothing
+		if offer.Paid == nil || offer.Paid.IsZero() || offer.Got.IsZero() {
+			continue
 		}
+		trades = append(trades, newTrade(account, offer))
 	}
 	sort.Sort(trades)
 	return trades, nil
 }
 
+// Balances returns every ICC and IOU balance changed by the transaction,
+// including those of created and deleted accounts and trust lines, and
+// the fee destroyed.
 func (txm *TransactionWithMetaData) Balances() (BalanceSlice, error) {
-	if txm.GetTransactionType() != OFFER_CREATE && txm.GetTransactionType() != PAYMENT {
-		return nil, nil
-	}
 	var (
 		balances BalanceSlice
-		account  = txm.Transaction.GetBase().Account
+		base     = txm.GetBase()
+		final    = zeroNative.Clone()
 	)
-	for _, node := range txm.MetaData.AffectedNodes {
-		switch {
-		case node.CreatedNode != nil:
-			switch node.CreatedNode.LedgerEntryType {
-			case ACCOUNT_ROOT:
-				created := node.CreatedNode.NewFields.(*AccountRoot)
-				balances.Add(created.Account, &zeroNative, created.Balance, &zeroCurrency)
-			case RIPPLE_STATE:
-				// New trust line
-				state := node.CreatedNode.NewFields.(*RippleState)
-				balances.Add(&account, &zeroNonNative, state.Balance.Value, &state.Balance.Currency)
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, current, previous, state := effect.AffectedNode()
+		switch node.LedgerEntryType {
+		case ACCOUNT_ROOT:
+			current, previous := current.(*AccountRoot), previous.(*AccountRoot)
+			if current.Account == nil {
+				// Only PreviousTxnID changed
+				continue
 			}
-		case node.DeletedNode != nil:
-			switch node.DeletedNode.LedgerEntryType {
-			case RIPPLE_STATE:
-				//?
-			case ACCOUNT_ROOT:
-				return nil, fmt.Errorf("Deleted AccountRoot!")
+			if current.Account.Equals(base.Account) && current.Balance != nil {
+				final = current.Balance
 			}
-		case node.ModifiedNode != nil:
-			if node.ModifiedNode.PreviousFields == nil {
-				// No change
-				continue
+			if err := txm.addNativeChange(&balances, previous, current, state); err != nil {
+				return nil, err
 			}
-			switch node.ModifiedNode.LedgerEntryType {
-			case ACCOUNT_ROOT:
-				// Changed ICC Balance
-				var (
-					previous = node.ModifiedNode.PreviousFields.(*AccountRoot)
-					current  = node.ModifiedNode.FinalFields.(*AccountRoot)
-				)
-				if previous.Balance == nil {
-					// ownercount change
-					continue
-				}
-				change, err := NewAmount(int64(current.Balance.num - previous.Balance.num))
-				if err != nil {
-					return nil, err
-				}
-				// Add fee and see if change is non-zero
-				if current.Account.Equals(account) {
-					change.Value, err = change.Value.Add(txm.GetBase().Fee)
-					if err != nil {
-						return nil, err
-					}
-				}
-				if change.num != 0 {
-					balances.Add(current.Account, current.Balance, change.Value, &zeroCurrency)
-				}
-			case RIPPLE_STATE:
-				// Changed non-native balance
-				var (
-					previous = node.ModifiedNode.PreviousFields.(*RippleState)
-					current  = node.ModifiedNode.FinalFields.(*RippleState)
-				)
-				if previous.Balance == nil {
-					//flag change
-					continue
-				}
-				change, err := current.Balance.Subtract(previous.Balance)
-				if err != nil {
-					return nil, err
-				}
-				balances.Add(&current.HighLimit.Issuer, current.Balance.Value, change.Value, &current.Balance.Currency)
-				balances.Add(&current.LowLimit.Issuer, current.Balance.Value.Negate(), change.Value.Negate(), &current.Balance.Currency)
+		case RIPPLE_STATE:
+			if err := addRippleChange(&balances, previous.(*RippleState), current.(*RippleState), state); err != nil {
+				return nil, err
 			}
 		}
 	}
+	if !base.Fee.IsZero() {
+		balances = append(balances, Balance{
+			Account:  base.Account,
+			Balance:  *final,
+			Change:   *base.Fee.Negate(),
+			Currency: zeroCurrency,
+			Fee:      true,
+		})
+	}
 	sort.Sort(balances)
 	return balances, nil
 }
+
+// addNativeChange adds the ICC balance change between previous and current
+// AccountRoots, excluding the fee paid by the transaction's account.
+func (txm *TransactionWithMetaData) addNativeChange(balances *BalanceSlice, previous, current *AccountRoot, state LedgerEntryState) error {
+	before := previous.Balance
+	if state == Created {
+		before = &zeroNative
+	}
+	if before == nil || current.Balance == nil {
+		// ownercount change
+		return nil
+	}
+	change, err := current.Balance.Subtract(*before)
+	if err != nil {
+		return err
+	}
+	// Add fee and see if change is non-zero
+	if current.Account.Equals(txm.GetBase().Account) {
+		change, err = change.Add(txm.GetBase().Fee)
+		if err != nil {
+			return err
+		}
+	}
+	if !change.IsZero() {
+		balances.Add(current.Account, &zeroAccount, current.Balance, change, &zeroCurrency)
+	}
+	return nil
+}
+
+// addRippleChange adds the IOU balance change of a trust line for both
+// of its accounts. A positive Balance means the low account holds IOUs
+// issued by the high account.
+func addRippleChange(balances *BalanceSlice, previous, current *RippleState, state LedgerEntryState) error {
+	before := previous.Balance
+	if state == Created && current.Balance != nil {
+		before = current.Balance.ZeroClone()
+	}
+	if before == nil || current.Balance == nil {
+		// flag, limit or quality change
+		return nil
+	}
+	if current.LowLimit == nil || current.HighLimit == nil {
+		return fmt.Errorf("RippleState missing LowLimit or HighLimit")
+	}
+	change, err := current.Balance.Subtract(before)
+	if err != nil {
+		return err
+	}
+	if change.IsZero() {
+		return nil
+	}
+	low, high := &current.LowLimit.Issuer, &current.HighLimit.Issuer
+	balances.Add(low, high, current.Balance.Value, change.Value, &current.Balance.Currency)
+	balances.Add(high, low, current.Balance.Value.Negate(), change.Value.Negate(), &current.Balance.Currency)
+	return nil
+}
@@ -3,8 +3,8 @@ package data
 import (
 	"encoding/json"
 	// "fmt"
-	"io/ioutil"
 
+	internal "github.com/wangch/ripple/testing"
 	. "gopkg.in/check.v1"
 )
 
@@ -12,28 +12,407 @@ type RippleSuite struct{}
 
 var _ = Suite(&RippleSuite{})
 
-var expectedTradesAndBalances = map[string]struct {
+// readTransactionFixture loads a transaction taken from the Ripple network
+func readTransactionFixture(file string) (*TransactionWithMetaData, error) {
+	b, err := internal.ReadFixture("testdata/" + file)
+	if err != nil {
+		return nil, err
+	}
+	var txm TransactionWithMetaData
+	if err := json.Unmarshal(b, &txm); err != nil {
+		return nil, err
+	}
+	return &txm, nil
+}
+
+var expectedEffects = map[string]struct {
 	Balances    int
+	Offers      int
+	OwnerCounts int
 	Trades      int
 	TotalTrades *Amount
 }{
-	"transaction_offercreate.json": {26, 8, amountCheck("8/BTC")},
+	"transaction_account_delete.json":        {3, 0, 0, 0, nil},
+	"transaction_account_set.json":           {1, 0, 0, 0, nil},
+	"transaction_fee_settings.json":          {0, 0, 0, 0, nil},
+	"transaction_offercreate.json":           {28, 8, 8, 8, amountCheck("8/BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")},
+	"transaction_payment_bug.json":           {5, 2, 1, 0, nil},
+	"transaction_payment_with_rippling.json": {11, 0, 0, 0, nil},
 }
 
-func (s *JSONSuite) TestTradesAndBalances(c *C) {
-	for file, expected := range expectedTradesAndBalances {
-		b, err := ioutil.ReadFile("testdata/" + file)
-		c.Assert(err, IsNil)
-		var txm TransactionWithMetaData
-		c.Assert(json.Unmarshal(b, &txm), IsNil)
+func (s *RippleSuite) TestEffects(c *C) {
+	for file, expected := range expectedEffects {
+		txm, err := readTransactionFixture(file)
+		c.Assert(err, IsNil, Commentf(file))
+		effects, err := txm.Effects()
+		c.Assert(err, IsNil, Commentf(file))
+		c.Check(effects.Balances, HasLen, expected.Balances, Commentf(file))
+		c.Check(effects.Offers, HasLen, expected.Offers, Commentf(file))
+		c.Check(effects.OwnerCounts, HasLen, expected.OwnerCounts, Commentf(file))
 		trades, err := txm.Trades()
-		c.Check(err, IsNil)
-		c.Check(len(trades), Equals, expected.Trades)
-		balances, err := txm.Balances()
-		c.Check(err, IsNil)
-		c.Check(len(balances), Equals, expected.Balances)
-		// sum, err := trades.Sum()
-		// c.Check(err, IsNil)
-		// c.Check(sum.Equals(*expected.TotalTrades), Equals, true)
+		c.Check(err, IsNil, Commentf(file))
+		c.Check(trades, HasLen, expected.Trades, Commentf(file))
+		sum, err := trades.Sum()
+		c.Check(err, IsNil, Commentf(file))
+		if expected.TotalTrades == nil {
+			c.Check(sum, IsNil, Commentf(file))
+		} else {
+			c.Check(sum.Equals(*expected.TotalTrades), Equals, true, Commentf(file))
+		}
+
+		// Everything but the fee moves from one account to another
+		totals := make(map[Currency]*Value)
+		for _, balance := range effects.Balances {
+			if balance.Fee {
+				c.Check(balance.Change.Equals(*txm.GetBase().Fee.Negate()), Equals, true, Commentf(file))
+				continue
+			}
+			if totals[balance.Currency] == nil {
+				totals[balance.Currency] = balance.Change.ZeroClone()
+			}
+			totals[balance.Currency], err = totals[balance.Currency].Add(balance.Change)
+			c.Assert(err, IsNil)
+		}
+		for currency, total := range totals {
+			c.Check(total.IsZero(), Equals, true, Commentf("%s %s: %s", file, currency, total))
+		}
 	}
 }
+
+func (s *RippleSuite) TestOfferCreateEffects(c *C) {
+	txm, err := readTransactionFixture("transaction_offercreate.json")
+	c.Assert(err, IsNil)
+	effects, err := txm.Effects()
+	c.Assert(err, IsNil)
+	account := txm.GetBase().Account.String()
+
+	var fee, btc *Balance
+	for i, balance := range effects.Balances {
+		switch {
+		case balance.Account.String() != account:
+		case balance.Fee:
+			fee = &effects.Balances[i]
+		case !balance.Currency.IsNative():
+			btc = &effects.Balances[i]
+		}
+	}
+	c.Assert(fee, NotNil)
+	c.Check(fee.Change.String(), Equals, "-0.000015")
+	c.Check(fee.Balance.String(), Equals, "518480.491128")
+	c.Assert(btc, NotNil)
+	c.Check(btc.Change.String(), Equals, "8")
+	c.Check(btc.Balance.String(), Equals, "8")
+	c.Check(btc.Counterparty.String(), Equals, "ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")
+
+	partial := effects.Offers[0]
+	c.Check(partial.Action, Equals, OfferPartiallyConsumed)
+	c.Check(partial.Account.String(), Equals, "iwBYyfufTzk77zUSKEu4MvrxfaiC35av1J")
+	c.Check(partial.Sequence, Equals, uint32(2308))
+	c.Check(partial.Paid.String(), Equals, "131885.794565/ICC")
+	c.Check(partial.Got.String(), Equals, "2.032398983215035/BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")
+	for _, offer := range effects.Offers[1:] {
+		c.Check(offer.Action, Equals, OfferConsumed)
+	}
+
+	c.Check(effects.OwnerCounts[0].Account.String(), Equals, account)
+	c.Check(effects.OwnerCounts[0].Previous, Equals, uint32(3))
+	c.Check(effects.OwnerCounts[0].Current, Equals, uint32(4))
+}
+
+func (s *RippleSuite) TestAccountDeleteBalances(c *C) {
+	txm, err := readTransactionFixture("transaction_account_delete.json")
+	c.Assert(err, IsNil)
+	c.Check(txm.MetaData.AffectedNodes[0].DeletedNode, NotNil)
+	balances, err := txm.Balances()
+	c.Assert(err, IsNil)
+	c.Assert(balances, HasLen, 3)
+
+	// The deleted account's balance, less the fee, goes to the destination
+	deleted := "iwYmyRm47wZcqZHjQjPQMPeum5wnLZKBE7"
+	for i, expected := range []struct {
+		account, balance, change string
+		fee                      bool
+	}{
+		{deleted, "0", "-2", true},
+		{"iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf", "123", "23", false},
+		{deleted, "0", "-23", false},
+	} {
+		c.Check(balances[i].Account.String(), Equals, expected.account, Commentf("%d", i))
+		c.Check(balances[i].Balance.String(), Equals, expected.balance, Commentf("%d", i))
+		c.Check(balances[i].Change.String(), Equals, expected.change, Commentf("%d", i))
+		c.Check(balances[i].Fee, Equals, expected.fee, Commentf("%d", i))
+		c.Check(balances[i].Currency.IsNative(), Equals, true, Commentf("%d", i))
+	}
+	c.Check(txm.MetaData.DeliveredAmount.String(), Equals, "23/ICC")
+}
+
+func (s *RippleSuite) TestOfferActions(c *C) {
+	txm, err := readTransactionFixture("transaction_payment_bug.json")
+	c.Assert(err, IsNil)
+	offers, err := txm.OfferChanges()
+	c.Assert(err, IsNil)
+	c.Assert(offers, HasLen, 2)
+	c.Check(offers[0].Action, Equals, OfferCreated)
+	c.Check(offers[0].Sequence, Equals, uint32(4))
+	c.Check(offers[0].Paid, IsNil)
+	c.Check(offers[0].Quality, Not(Equals), ExchangeRate(0))
+	c.Check(offers[1].Action, Equals, OfferConsumed)
+	c.Check(offers[1].Paid.IsZero(), Equals, true)
+}
+
+func (s *RippleSuite) TestTransfers(c *C) {
+	txm, err := readTransactionFixture("transaction_payment_with_rippling.json")
+	c.Assert(err, IsNil)
+	transfers, err := txm.Transfers()
+	c.Assert(err, IsNil)
+	c.Assert(transfers, HasLen, 5)
+	delivered := zeroNonNative.Clone()
+	for _, t := range transfers {
+		c.Check(t.TransitFee, IsNil)
+		c.Check(t.QualityOut, IsNil)
+		if t.Destination.String() == "ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B" {
+			delivered, err = delivered.Add(t.Change)
+			c.Assert(err, IsNil)
+		}
+	}
+	c.Check(delivered.String(), Equals, "20")
+	// The liquidity provider values incoming IOUs at 92%
+	c.Check(transfers[1].Source.String(), Equals, "inzrPaiaNb8nsU4aiuQdwYE3j5jUcqjzFm")
+	c.Assert(transfers[1].QualityIn, NotNil)
+	c.Check(transfers[1].QualityIn.String(), Equals, "0.042173912716")
+
+	txm, err = readTransactionFixture("transaction_offercreate.json")
+	c.Assert(err, IsNil)
+	transfers, err = txm.Transfers()
+	c.Assert(err, IsNil)
+	c.Assert(transfers, HasLen, 17)
+	var fees []Transfer
+	for _, t := range transfers {
+		if t.TransitFee != nil {
+			fees = append(fees, t)
+		}
+	}
+	// Bitstamp charges 0.2% to pass on the BTC the offers sold
+	c.Assert(fees, HasLen, 1)
+	c.Check(fees[0].Change.String(), Equals, "8")
+	c.Check(fees[0].TransitFee.String(), Equals, "0.015999999999936")
+	c.Check(fees[0].DestinationBalance.Currency.String(), Equals, "BTC")
+	// The ICC the taker sold goes to the owners of the offers it took
+	offers, err := txm.OfferChanges()
+	c.Assert(err, IsNil)
+	paid := make(map[Account]string)
+	for _, offer := range offers {
+		paid[offer.Account] = offer.Paid.Value.String()
+	}
+	for _, t := range transfers {
+		if t.SourceBalance.Currency.IsNative() {
+			c.Check(t.Change.String(), Equals, paid[t.Destination])
+		}
+	}
+
+	// The offer's owner sells USD which its issuer passes on for a fee
+	txm, err = readTransactionFixture("transaction_payment_bug.json")
+	c.Assert(err, IsNil)
+	transfers, err = txm.Transfers()
+	c.Assert(err, IsNil)
+	c.Assert(transfers, HasLen, 2)
+	for _, t := range transfers {
+		c.Check(t.QualityIn, IsNil)
+		c.Check(t.QualityOut, IsNil)
+	}
+	c.Check(transfers[0].Source.String(), Equals, "ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")
+	c.Assert(transfers[0].TransitFee, NotNil)
+	c.Check(transfers[0].TransitFee.String(), Equals, "7301047904192e-25")
+}
+
+func (s *RippleSuite) TestNFTokenChanges(c *C) {
+	issuer := "iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn"
+	first := "000901F4AE123A8556F3CF91154711376AFB0F894F832B3D0000099B00000000"
+	second := "000901F4AE123A8556F3CF91154711376AFB0F894F832B3D16E5DA9C00000001"
+	check := func(changes NFTokenChangeSlice, account, id string) {
+		c.Assert(changes, HasLen, 1)
+		c.Check(changes[0].Account.String(), Equals, account)
+		c.Check(changes[0].NFTokenID.String(), Equals, id)
+	}
+
+	// The second token joins the first on the issuer's page
+	txm, err := readTransactionFixture("transaction_nftoken_mint.json")
+	c.Assert(err, IsNil)
+	check(txm.MintedNFTokens(), issuer, second)
+	c.Check(txm.BurnedNFTokens(), IsNil)
+	page := txm.MetaData.AffectedNodes[1].ModifiedNode.FinalFields.(*NFTokenPage)
+	c.Assert(page.NFTokens, HasLen, 2)
+	c.Check(page.NFTokens[1].NFToken.URI.String(), Equals, "697066733A2F2F62616679626569")
+	info := NewNFTokenInfo(page.NFTokens[1].NFToken.NFTokenID)
+	c.Check(info.Issuer.String(), Equals, issuer)
+	c.Check(info.Serial, Equals, uint32(1))
+	c.Check(info.Taxon, Equals, uint32(0))
+	c.Check(info.TransferFee, Equals, uint16(500))
+	c.Check(info.Transferable(), Equals, true)
+
+	txm, err = readTransactionFixture("transaction_nftoken_burn.json")
+	c.Assert(err, IsNil)
+	check(txm.BurnedNFTokens(), issuer, first)
+	c.Check(txm.MintedNFTokens(), IsNil)
+
+	// The seller's emptied page is deleted and the buyer's created
+	txm, err = readTransactionFixture("transaction_nftoken_accept_offer.json")
+	c.Assert(err, IsNil)
+	from, to := txm.TransferredNFTokens()
+	check(from, issuer, second)
+	check(to, "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9", second)
+	c.Check(txm.MintedNFTokens(), IsNil)
+	c.Check(txm.BurnedNFTokens(), IsNil)
+}
+
+func (s *RippleSuite) TestNativeTransfers(c *C) {
+	icc := func(address, change string, fee bool) Balance {
+		value := builderAmount(c, change+"/ICC").Value
+		return Balance{Account: builderAccount(c, address), Balance: *value, Change: *value, Fee: fee}
+	}
+	transfers, err := nativeTransfers(BalanceSlice{
+		icc("iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn", "-5", false),
+		icc("iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn", "-0.00001", true),
+		icc("iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9", "-3", false),
+		icc("iGWiZyQqhTp9Xu7G5Pkayo7bXjH4k4QYpf", "-2", false),
+		icc("iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX", "3", false),
+		icc("ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B", "1", false),
+		icc("ihQ69TqAvwqcQRijE1t5D8CFRczigaPXrz", "5", false),
+		icc("ia7jD61UA16nwb8bd8iYPss6ZJN3Zdnyff", "1", false),
+	})
+	c.Assert(err, IsNil)
+	var flows []string
+	for _, t := range transfers {
+		flows = append(flows, t.Source.String()[:6]+" "+t.Destination.String()[:6]+" "+t.Change.String())
+	}
+	// Equal amounts are paired first, whatever their order
+	c.Check(flows, DeepEquals, []string{
+		"iG1QQv ihQ69T 5",
+		"iNPRNz iMWUyk 3",
+		"iGWiZy ivYAfW 1",
+		"iGWiZy ia7jD6 1",
+	})
+}
+
+func (s *RippleSuite) TestAttributeFees(c *C) {
+	issuer := builderAccount(c, "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX")
+	through := builderAccount(c, "iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn")
+	holder := builderAccount(c, "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9")
+	in := gatewayLine(c, through, "10/USD/"+issuer.String(), false)
+	out := gatewayLine(c, holder, "9/USD/"+through.String(), false)
+	attribute := func(traded map[flowKey]bool) Transfer {
+		transfers := TransferSlice{
+			{Source: issuer, Destination: through, SourceBalance: builderAmount(c, "0/USD/"+issuer.String()), Change: *builderAmount(c, "10/USD").Value},
+			{Source: through, Destination: holder, SourceBalance: builderAmount(c, "0/USD/"+holder.String()), Change: *builderAmount(c, "9/USD").Value},
+		}
+		c.Assert(attributeFees(transfers, []hop{{0, issuer, in}, {1, through, out}}, traded), IsNil)
+		return transfers[1]
+	}
+
+	// Without a quality on its lines, what was kept is not explained
+	t := attribute(nil)
+	c.Check(t.QualityIn, IsNil)
+	c.Check(t.QualityOut, IsNil)
+
+	quality := uint32(900000000)
+	if in.LowLimit.Issuer.Equals(through) {
+		in.LowQualityIn = &quality
+	} else {
+		in.HighQualityIn = &quality
+	}
+	t = attribute(nil)
+	c.Assert(t.QualityIn, NotNil)
+	c.Check(t.QualityIn.String(), Equals, "1")
+
+	// The spread of an offer is not a fee
+	t = attribute(map[flowKey]bool{{through, in.Balance.Currency}: true})
+	c.Check(t.QualityIn, IsNil)
+}
+
+func (s *RippleSuite) TestDeliveredAmount(c *C) {
+	txm, err := readTransactionFixture("transaction_payment_with_rippling.json")
+	c.Assert(err, IsNil)
+	payment := txm.Transaction.(*Payment)
+	delivered, err := txm.DeliveredAmount()
+	c.Assert(err, IsNil)
+	c.Check(delivered.String(), Equals, payment.Amount.String())
+
+	// Partial payments are reconstructed from the destination's lines
+	flags := TxPartialPayment
+	payment.Flags = &flags
+	delivered, err = txm.DeliveredAmount()
+	c.Assert(err, IsNil)
+	c.Check(delivered.String(), Equals, "20/USD/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")
+
+	// An issuer other than the destination must match the counterparty
+	issuer, err := NewAccountFromAddress("ipDMez6pm6dBve2TJsmDpv7Yae6V5Pyvy2")
+	c.Assert(err, IsNil)
+	payment.Amount.Issuer = *issuer
+	_, err = txm.DeliveredAmount()
+	c.Check(err, Equals, ErrDeliveredAmountUnknown)
+
+	txm.MetaData.DeliveredAmount, err = NewAmount("5/USD/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B")
+	c.Assert(err, IsNil)
+	delivered, err = txm.DeliveredAmount()
+	c.Assert(err, IsNil)
+	c.Check(delivered, Equals, txm.MetaData.DeliveredAmount)
+
+	txm.MetaData.TransactionResult = tecPATH_PARTIAL
+	delivered, err = txm.DeliveredAmount()
+	c.Assert(err, IsNil)
+	c.Check(delivered.IsZero(), Equals, true)
+
+	txm, err = readTransactionFixture("transaction_offercreate.json")
+	c.Assert(err, IsNil)
+	_, err = txm.DeliveredAmount()
+	c.Check(err, ErrorMatches, "Not a Payment: OfferCreate")
+}
+
+func (s *RippleSuite) TestTrades(c *C) {
+	txm, err := readTransactionFixture("transaction_offercreate.json")
+	c.Assert(err, IsNil)
+	trades, err := txm.Trades()
+	c.Assert(err, IsNil)
+	c.Assert(trades, HasLen, 8)
+	btc := amountCheck("0/BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B").Issue()
+	pair := CurrencyPair{btc, Issue{}}
+	c.Check(pair.String(), Equals, "BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B:ICC")
+	for i, trade := range trades {
+		c.Check(trade.Pair, Equals, pair)
+		c.Check(trade.Side, Equals, TakerBuy)
+		c.Check(trade.Taker, Equals, txm.GetBase().Account)
+		c.Check(trade.Base().Equals(trade.Got), Equals, true)
+		if i > 0 {
+			c.Check(trades[i-1].Price().Less(*trade.Price()), Equals, true)
+		}
+	}
+	whole := trades[3]
+	c.Check(whole.Maker.String(), Equals, "ia7jD61UA16nwb8bd8iYPss6ZJN3Zdnyff")
+	c.Check(whole.Sequence, Equals, uint32(10069))
+	c.Check(whole.Price().String(), Equals, "64400")
+	inverse, err := whole.PriceIn(pair.Inverse())
+	c.Assert(err, IsNil)
+	c.Check(inverse.String(), Equals, "0.00001552795031055901")
+	_, err = whole.PriceIn(CurrencyPair{btc, btc})
+	c.Check(err, NotNil)
+
+	// Dust taken from an unfunded offer is not a trade
+	txm, err = readTransactionFixture("transaction_payment_bug.json")
+	c.Assert(err, IsNil)
+	trades, err = txm.Trades()
+	c.Assert(err, IsNil)
+	c.Check(trades, HasLen, 0)
+}
+
+func (s *RippleSuite) TestCurrencyPair(c *C) {
+	var (
+		usd = amountCheck("0/USD/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B").Issue()
+		btc = amountCheck("0/BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B").Issue()
+		icc = amountCheck("1/ICC").Issue()
+	)
+	c.Check(NewCurrencyPair(icc, usd), Equals, CurrencyPair{usd, icc})
+	c.Check(NewCurrencyPair(usd, icc), Equals, CurrencyPair{usd, icc})
+	c.Check(NewCurrencyPair(usd, btc), Equals, CurrencyPair{btc, usd})
+	c.Check(NewCurrencyPair(btc, usd).Matches(CurrencyPair{usd, btc}), Equals, true)
+	c.Check(NewCurrencyPair(btc, usd).Matches(CurrencyPair{usd, icc}), Equals, false)
+}
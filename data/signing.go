@@ -1,9 +1,22 @@
 package data
 
-import "github.com/wangch/ripple/crypto"
+import (
+	"fmt"
 
+	"github.com/wangch/ripple/crypto"
+)
+
+// Sign always produces a fully canonical signature, so transactions
+// are flagged with tfFullyCanonicalSig to stop others malleating them
 func Sign(s Signer, key crypto.Key, sequence *uint32) error {
 	s.InitialiseForSigning()
+	if tx, ok := s.(Transaction); ok {
+		base := tx.GetBase()
+		if base.Flags == nil {
+			base.Flags = new(TransactionFlag)
+		}
+		*base.Flags |= TxCanonicalSignature
+	}
 	copy(s.GetPublicKey().Bytes(), key.Public(sequence))
 	hash, msg, err := SigningHash(s)
 	if err != nil {
@@ -14,15 +27,36 @@ func Sign(s Signer, key crypto.Key, sequence *uint32) error {
 		return err
 	}
 	*s.GetSignature() = VariableLength(sig)
-	hash, _, err = Raw(s)
+	hashes, err := AllHashes(s)
 	if err != nil {
 		return err
 	}
-	copy(s.GetHash().Bytes(), hash.Bytes())
+	if hashes.SigningHash != hash {
+		return fmt.Errorf("Signing changed the signed fields")
+	}
+	copy(s.GetHash().Bytes(), hashes.NodeId.Bytes())
 	return nil
 }
 
+// CheckSignature verifies the signature, insisting on a fully canonical
+// one when a transaction is flagged with tfFullyCanonicalSig
 func CheckSignature(s Signer) (bool, error) {
+	required := crypto.NonCanonical
+	if tx, ok := s.(Transaction); ok {
+		if flags := tx.GetBase().Flags; flags != nil && *flags&TxCanonicalSignature > 0 {
+			required = crypto.FullyCanonical
+		}
+	}
+	return CheckCanonicalSignature(s, required)
+}
+
+// CheckCanonicalSignature verifies the signature and that it is at
+// least as canonical as required
+func CheckCanonicalSignature(s Signer, required crypto.Canonicality) (bool, error) {
+	canonicality := crypto.CheckCanonical(s.GetPublicKey().Bytes(), s.GetSignature().Bytes())
+	if canonicality < required {
+		return false, fmt.Errorf("Signature is %s, %s required", canonicality, required)
+	}
 	hash, msg, err := SigningHash(s)
 	if err != nil {
 		return false, err
@@ -0,0 +1,290 @@
+package data
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DecodeSTObject reads any serialized object into its JSON form, in
+// the same shape encoding/json produces when unmarshalling into an
+// interface{}. Nested objects become maps and arrays become slices of
+// single key maps, so no Go struct is needed for the object's type.
+func DecodeSTObject(b []byte) (map[string]interface{}, error) {
+	return readSTObject(bytes.NewReader(b), false)
+}
+
+// EncodeSTObject writes the JSON form of an object in canonical binary
+// order. It accepts the output of DecodeSTObject or of json.Unmarshal.
+func EncodeSTObject(obj map[string]interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeSTObject(&b, obj); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// DecodeSTObjectHex and EncodeSTObjectHex work with the hex strings
+// used by rippled's RPC interface
+func DecodeSTObjectHex(s string) (map[string]interface{}, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeSTObject(b)
+}
+
+func EncodeSTObjectHex(obj map[string]interface{}) (string, error) {
+	b, err := EncodeSTObject(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(b2h(b)), nil
+}
+
+func readSTObject(r Reader, nested bool) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	for {
+		e, err := readEncoding(r)
+		switch {
+		case err == io.EOF && !nested:
+			return obj, nil
+		case err != nil:
+			return nil, err
+		}
+		name, ok := encodings[*e]
+		if !ok {
+			return nil, fmt.Errorf("Unknown field: type %d field %d", e.typ, e.field)
+		}
+		switch {
+		case name == "EndOfObject" && nested:
+			return obj, nil
+		case e.typ == ST_OBJECT:
+			if obj[name], err = readSTObject(r, true); err != nil {
+				return nil, err
+			}
+		case e.typ == ST_ARRAY:
+			if obj[name], err = readSTArray(r); err != nil {
+				return nil, err
+			}
+		default:
+			if obj[name], err = readSTValue(r, *e, name); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err.Error())
+			}
+		}
+	}
+}
+
+func readSTArray(r Reader) ([]interface{}, error) {
+	array := []interface{}{}
+	for {
+		e, err := readEncoding(r)
+		if err != nil {
+			return nil, err
+		}
+		name := encodings[*e]
+		switch {
+		case name == "EndOfArray":
+			return array, nil
+		case e.typ != ST_OBJECT || name == "":
+			return nil, fmt.Errorf("Unexpected array member: type %d field %d", e.typ, e.field)
+		}
+		inner, err := readSTObject(r, true)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, map[string]interface{}{name: inner})
+	}
+}
+
+// newSTValue returns a pointer to the Go type used for a field's value
+func newSTValue(e enc, name string) interface{} {
+	switch name {
+	case "TransactionType":
+		return new(TransactionType)
+	case "LedgerEntryType":
+		return new(LedgerEntryType)
+	case "TransactionResult":
+		return new(TransactionResult)
+	}
+	switch e.typ {
+	case ST_UINT8:
+		return new(uint8)
+	case ST_UINT16:
+		return new(uint16)
+	case ST_UINT32:
+		return new(uint32)
+	case ST_UINT64:
+		return new(Uint64Hex)
+	case ST_HASH128:
+		return new(Hash128)
+	case ST_HASH160:
+		return new(Hash160)
+	case ST_HASH256:
+		return new(Hash256)
+	case ST_AMOUNT:
+		return new(Amount)
+	case ST_VL:
+		return new(VariableLength)
+	case ST_ACCOUNT:
+		return new(Account)
+	case ST_PATHSET:
+		return new(PathSet)
+	case ST_VECTOR256:
+		return new(Vector256)
+	}
+	if variableTypes[e.typ] {
+		return new(VariableLength)
+	}
+	return nil
+}
+
+func readSTValue(r Reader, e enc, name string) (interface{}, error) {
+	value := newSTValue(e, name)
+	switch v := value.(type) {
+	case nil:
+		// A fixed width type known only from loaded definitions
+		width, ok := typeWidths[e.typ]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported type: %d", e.typ)
+		}
+		b := make([]byte, width)
+		if err := unmarshalSlice(b, r, "Value"); err != nil {
+			return nil, err
+		}
+		return string(b2h(b)), nil
+	case Wire:
+		if err := v.Unmarshal(r); err != nil {
+			return nil, err
+		}
+	default:
+		if err := read(r, v); err != nil {
+			return nil, err
+		}
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type stField struct {
+	enc   enc
+	name  string
+	value interface{}
+}
+
+type stFieldSlice []stField
+
+func (s stFieldSlice) Len() int           { return len(s) }
+func (s stFieldSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s stFieldSlice) Less(i, j int) bool { return s[i].enc.Priority() < s[j].enc.Priority() }
+
+func sortSTFields(obj map[string]interface{}) (stFieldSlice, error) {
+	fields := make(stFieldSlice, 0, len(obj))
+	for name, value := range obj {
+		e, ok := reverseEncodings[name]
+		if !ok {
+			// Keys such as "hash" are not part of the binary form
+			if name != "" && strings.ToLower(name[:1]) == name[:1] {
+				continue
+			}
+			return nil, fmt.Errorf("Unknown field: %s", name)
+		}
+		fields = append(fields, stField{e, name, value})
+	}
+	sort.Sort(fields)
+	return fields, nil
+}
+
+func writeSTObject(w io.Writer, obj map[string]interface{}) error {
+	fields, err := sortSTFields(obj)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := writeEncoding(w, f.enc); err != nil {
+			return err
+		}
+		switch f.enc.typ {
+		case ST_OBJECT:
+			inner, ok := f.value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: expected object", f.name)
+			}
+			if err := writeSTObject(w, inner); err != nil {
+				return err
+			}
+			err = writeEncoding(w, reverseEncodings["EndOfObject"])
+		case ST_ARRAY:
+			err = writeSTArray(w, f.name, f.value)
+		default:
+			err = writeSTValue(w, f.enc, f.name, f.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSTArray(w io.Writer, name string, value interface{}) error {
+	array, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s: expected array", name)
+	}
+	for _, member := range array {
+		wrapper, ok := member.(map[string]interface{})
+		if !ok || len(wrapper) != 1 {
+			return fmt.Errorf("%s: array members must be single key objects", name)
+		}
+		for key := range wrapper {
+			if reverseEncodings[key].typ != ST_OBJECT {
+				return fmt.Errorf("%s: unexpected array member: %s", name, key)
+			}
+		}
+		if err := writeSTObject(w, wrapper); err != nil {
+			return err
+		}
+	}
+	return writeEncoding(w, reverseEncodings["EndOfArray"])
+}
+
+func writeSTValue(w io.Writer, e enc, name string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dest := newSTValue(e, name)
+	if dest == nil {
+		width, ok := typeWidths[e.typ]
+		if !ok {
+			return fmt.Errorf("%s: unsupported type: %d", name, e.typ)
+		}
+		var raw VariableLength
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return fmt.Errorf("%s: %s", name, err.Error())
+		}
+		if len(raw) != width {
+			return fmt.Errorf("%s: wrong length %d expected: %d", name, len(raw), width)
+		}
+		_, err := w.Write(raw)
+		return err
+	}
+	if err := json.Unmarshal(b, dest); err != nil {
+		return fmt.Errorf("%s: %s", name, err.Error())
+	}
+	if wire, ok := dest.(Wire); ok {
+		return wire.Marshal(w)
+	}
+	return write(w, dest)
+}
@@ -0,0 +1,252 @@
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DecodeError describes where a serialized object failed strict checking
+type DecodeError struct {
+	Offset int    // Offset of the field header, or of the value for errors in the value
+	Path   string // Path to the field, such as Memos[0].Memo.MemoType
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("offset %d: %s: %s", e.Offset, e.Path, e.Reason)
+}
+
+// CheckCanonical verifies that b is a single serialized object in the
+// exact form rippled would produce. Unlike the decoders, which skip
+// what they do not understand, it rejects fields out of canonical
+// order, duplicated fields, unknown fields and type codes,
+// non-canonical amounts, stray end markers and trailing bytes.
+func CheckCanonical(b []byte) error {
+	c := &canonicalChecker{b: b}
+	return c.object("", false)
+}
+
+// ReadTransactionStrict decodes a transaction only if it passes CheckCanonical
+func ReadTransactionStrict(b []byte) (Transaction, error) {
+	if err := CheckCanonical(b); err != nil {
+		return nil, err
+	}
+	return ReadTransaction(bytes.NewReader(b))
+}
+
+type canonicalChecker struct {
+	b   []byte
+	pos int
+}
+
+func (c *canonicalChecker) fail(offset int, path, format string, args ...interface{}) error {
+	return &DecodeError{Offset: offset, Path: path, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (c *canonicalChecker) next(n int, path string) ([]byte, error) {
+	if c.pos+n > len(c.b) {
+		return nil, c.fail(c.pos, path, "need %d bytes, %d remaining", n, len(c.b)-c.pos)
+	}
+	b := c.b[c.pos : c.pos+n]
+	c.pos += n
+	return b, nil
+}
+
+// header reads a field header, insisting on its shortest encoding
+func (c *canonicalChecker) header(path string) (*enc, error) {
+	start := c.pos
+	b, err := c.next(1, path)
+	if err != nil {
+		return nil, err
+	}
+	e := &enc{b[0] >> 4, b[0] & 0xF}
+	if e.typ == 0 {
+		if b, err = c.next(1, path); err != nil {
+			return nil, err
+		}
+		if e.typ = b[0]; e.typ < 16 {
+			return nil, c.fail(start, path, "non-canonical field header for type %d", e.typ)
+		}
+	}
+	if e.field == 0 {
+		if b, err = c.next(1, path); err != nil {
+			return nil, err
+		}
+		if e.field = b[0]; e.field < 16 {
+			return nil, c.fail(start, path, "non-canonical field header for field %d", e.field)
+		}
+	}
+	return e, nil
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (c *canonicalChecker) object(path string, nested bool) error {
+	var previous uint32
+	for {
+		if !nested && c.pos == len(c.b) {
+			return nil
+		}
+		start := c.pos
+		e, err := c.header(path)
+		if err != nil {
+			return err
+		}
+		name, ok := encodings[*e]
+		switch {
+		case !ok:
+			return c.fail(start, path, "unknown field: type %d field %d", e.typ, e.field)
+		case name == "EndOfObject" && nested:
+			return nil
+		case name == "EndOfObject", name == "EndOfArray":
+			return c.fail(start, path, "unexpected %s", name)
+		case e.Priority() == previous:
+			return c.fail(start, fieldPath(path, name), "duplicate field")
+		case e.Priority() < previous:
+			return c.fail(start, fieldPath(path, name), "field out of canonical order")
+		}
+		previous = e.Priority()
+		if err := c.value(fieldPath(path, name), e); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *canonicalChecker) array(path string) error {
+	for i := 0; ; i++ {
+		start := c.pos
+		e, err := c.header(path)
+		if err != nil {
+			return err
+		}
+		name, ok := encodings[*e]
+		switch {
+		case name == "EndOfArray":
+			return nil
+		case !ok || e.typ != ST_OBJECT || name == "EndOfObject":
+			return c.fail(start, path, "array member is not an object: type %d field %d", e.typ, e.field)
+		}
+		if err := c.object(fmt.Sprintf("%s[%d].%s", path, i, name), true); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *canonicalChecker) value(path string, e *enc) error {
+	start := c.pos
+	switch {
+	case e.typ == ST_OBJECT:
+		return c.object(path, true)
+	case e.typ == ST_ARRAY:
+		return c.array(path)
+	case e.typ == ST_AMOUNT:
+		return c.amount(path)
+	case e.typ == ST_PATHSET:
+		return c.pathSet(path)
+	case variableTypes[e.typ]:
+		length, err := readVariableLength(bytes.NewReader(c.b[c.pos:]))
+		if err != nil {
+			return c.fail(start, path, "%s", err.Error())
+		}
+		switch {
+		case length <= 192:
+			c.pos++
+		case length <= 12480:
+			c.pos += 2
+		default:
+			c.pos += 3
+		}
+		switch {
+		case e.typ == ST_ACCOUNT && length != 20:
+			return c.fail(start, path, "account length %d", length)
+		case e.typ == ST_VECTOR256 && length%32 != 0:
+			return c.fail(start, path, "vector length %d is not a multiple of 32", length)
+		}
+		_, err = c.next(length, path)
+		return err
+	}
+	if width, ok := typeWidths[e.typ]; ok {
+		_, err := c.next(width, path)
+		return err
+	}
+	return c.fail(start, path, "unknown type code %d", e.typ)
+}
+
+func (c *canonicalChecker) amount(path string) error {
+	start := c.pos
+	b, err := c.next(8, path)
+	if err != nil {
+		return err
+	}
+	u := binary.BigEndian.Uint64(b)
+	if u&notNative == 0 {
+		switch {
+		case u == 0:
+			// rippled normalizes a zero amount to positive
+			return c.fail(start, path, "negative native zero")
+		case u&^positive > maxNativeNetwork:
+			return c.fail(start, path, "native amount exceeds maximum")
+		}
+		return nil
+	}
+	mantissa, offset := u&(1<<54-1), int64((u>>54)&0xFF)-97
+	switch {
+	case mantissa == 0 && u != notNative:
+		return c.fail(start, path, "non-canonical zero amount")
+	case mantissa != 0 && (mantissa < minValue || mantissa > maxValue):
+		return c.fail(start, path, "mantissa %d is not normalized", mantissa)
+	case mantissa != 0 && (offset < minOffset || offset > maxOffset):
+		return c.fail(start, path, "exponent %d out of range", offset)
+	}
+	currency, err := c.next(20, path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(currency, zeroCurrency[:]) {
+		return c.fail(start+8, path, "issued amount uses the native currency code")
+	}
+	_, err = c.next(20, path)
+	return err
+}
+
+func (c *canonicalChecker) pathSet(path string) error {
+	empty := true
+	for {
+		start := c.pos
+		b, err := c.next(1, path)
+		if err != nil {
+			return err
+		}
+		switch entry := pathEntry(b[0]); {
+		case entry == PATH_END || entry == PATH_BOUNDARY:
+			if empty {
+				return c.fail(start, path, "empty path")
+			}
+			if entry == PATH_END {
+				return nil
+			}
+			empty = true
+		case entry&^(PATH_ACCOUNT|PATH_CURRENCY|PATH_ISSUER) != 0:
+			return c.fail(start, path, "invalid path element type %02X", b[0])
+		default:
+			empty = false
+			for _, flag := range []pathEntry{PATH_ACCOUNT, PATH_CURRENCY, PATH_ISSUER} {
+				if entry&flag == 0 {
+					continue
+				}
+				if _, err := c.next(20, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
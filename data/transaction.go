@@ -34,8 +34,8 @@ type AccountSet struct {
 	MessageKey    *VariableLength `json:",omitempty"`
 	Domain        *VariableLength `json:",omitempty"`
 	TransferRate  *uint32         `json:",omitempty"`
-	SetFlag       *uint32         `json:",omitempty"`
-	ClearFlag     *uint32         `json:",omitempty"`
+	SetFlag       *AccountSetFlag `json:",omitempty"`
+	ClearFlag     *AccountSetFlag `json:",omitempty"`
 }
 
 type SetRegularKey struct {
@@ -63,6 +63,53 @@ type TrustSet struct {
 	QualityOut  *uint32 `json:",omitempty"`
 }
 
+type DepositPreauth struct {
+	TxBase
+	Authorize   *Account `json:",omitempty"`
+	Unauthorize *Account `json:",omitempty"`
+}
+
+type AccountDelete struct {
+	TxBase
+	Destination    Account
+	DestinationTag *uint32 `json:",omitempty"`
+}
+
+type NFTokenMint struct {
+	TxBase
+	NFTokenTaxon uint32
+	Issuer       *Account        `json:",omitempty"`
+	TransferFee  *uint16         `json:",omitempty"`
+	URI          *VariableLength `json:",omitempty"`
+}
+
+type NFTokenBurn struct {
+	TxBase
+	NFTokenID Hash256
+	Owner     *Account `json:",omitempty"`
+}
+
+type NFTokenCreateOffer struct {
+	TxBase
+	NFTokenID   Hash256
+	Amount      Amount
+	Owner       *Account `json:",omitempty"`
+	Destination *Account `json:",omitempty"`
+	Expiration  *uint32  `json:",omitempty"`
+}
+
+type NFTokenCancelOffer struct {
+	TxBase
+	NFTokenOffers Vector256
+}
+
+type NFTokenAcceptOffer struct {
+	TxBase
+	NFTokenSellOffer *Hash256 `json:",omitempty"`
+	NFTokenBuyOffer  *Hash256 `json:",omitempty"`
+	NFTokenBrokerFee *Amount  `json:",omitempty"`
+}
+
 type SetFee struct {
 	TxBase
 	BaseFee           Uint64Hex
@@ -77,7 +124,7 @@ type Amendment struct {
 }
 
 func (t *TxBase) GetBase() *TxBase                    { return t }
-func (t *TxBase) GetType() string                     { return txNames[t.TransactionType] }
+func (t *TxBase) GetType() string                     { return t.TransactionType.String() }
 func (t *TxBase) GetTransactionType() TransactionType { return t.TransactionType }
 func (t *TxBase) Prefix() HashPrefix                  { return HP_TRANSACTION_ID }
 func (t *TxBase) GetPublicKey() *PublicKey            { return t.SigningPubKey }
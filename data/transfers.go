@@ -0,0 +1,332 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+)
+
+type TransferSlice []Transfer
+
+func (s TransferSlice) Len() int      { return len(s) }
+func (s TransferSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s TransferSlice) Less(i, j int) bool {
+	switch {
+	case !s[i].SourceBalance.Currency.Equals(s[j].SourceBalance.Currency):
+		return s[i].SourceBalance.Currency.Less(s[j].SourceBalance.Currency)
+	case !s[i].Source.Equals(s[j].Source):
+		return s[i].Source.Less(s[j].Source)
+	default:
+		return s[i].Destination.Less(s[j].Destination)
+	}
+}
+
+func (t Transfer) String() string {
+	s := fmt.Sprintf("%-34s => %-34s %20s %s", t.Source, t.Destination, t.Change, t.SourceBalance.Currency)
+	for _, extra := range []struct {
+		name  string
+		value *Value
+	}{{"TransitFee", t.TransitFee}, {"QualityIn", t.QualityIn}, {"QualityOut", t.QualityOut}} {
+		if extra.value != nil {
+			s += fmt.Sprintf(" %s: %s", extra.name, extra.value)
+		}
+	}
+	return s
+}
+
+// hop is a Transfer along a single trust line
+type hop struct {
+	index  int
+	issuer Account
+	line   *RippleState
+}
+
+// Transfers reconstructs the flow of value through the accounts a
+// transaction touched. Each changed trust line is a single IOU transfer.
+// ICC has no lines to follow, so ICC transfers pair the accounts which
+// lost ICC with those that gained exactly as much, as when an offer is
+// taken, and then pair whatever remains in order, which need not be how
+// the ICC actually flowed. Where an account passes on less than it
+// received in the same currency the difference is attributed to the
+// transfers it sent on, as a TransitFee when it issued both sides and
+// otherwise to a QualityIn or QualityOut it set on its lines. Differences
+// left by an account whose offers traded the currency are the spread of
+// those offers and are not attributed.
+func (txm *TransactionWithMetaData) Transfers() (TransferSlice, error) {
+	balances, err := txm.Balances()
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := nativeTransfers(balances)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := txm.OfferChanges()
+	if err != nil {
+		return nil, err
+	}
+	traded := make(map[flowKey]bool)
+	for _, offer := range offers {
+		traded[flowKey{offer.Account, offer.TakerPays.Currency}] = true
+		traded[flowKey{offer.Account, offer.TakerGets.Currency}] = true
+	}
+	var hops []hop
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, current, previous, state := effect.AffectedNode()
+		if node.LedgerEntryType != RIPPLE_STATE {
+			continue
+		}
+		transfer, issuer, err := rippleTransfer(previous.(*RippleState), current.(*RippleState), state)
+		if err != nil {
+			return nil, err
+		}
+		if transfer != nil {
+			hops = append(hops, hop{len(transfers), *issuer, current.(*RippleState)})
+			transfers = append(transfers, *transfer)
+		}
+	}
+	if err := attributeFees(transfers, hops, traded); err != nil {
+		return nil, err
+	}
+	sort.Sort(transfers)
+	return transfers, nil
+}
+
+// nativeTransfers pairs the ICC losses and gains in balances, excluding
+// the fee, matching equal amounts before pairing the rest in order
+func nativeTransfers(balances BalanceSlice) (TransferSlice, error) {
+	var sources, destinations BalanceSlice
+	for _, balance := range balances {
+		switch {
+		case balance.Fee || !balance.Currency.IsNative():
+		case balance.Change.IsNegative():
+			sources = append(sources, balance)
+		default:
+			destinations = append(destinations, balance)
+		}
+	}
+	var (
+		transfers TransferSlice
+		unpaired  BalanceSlice
+		matched   = make([]bool, len(destinations))
+	)
+	for _, source := range sources {
+		paired := false
+		for j, destination := range destinations {
+			if !matched[j] && source.Change.Abs().Equals(*destination.Change.Abs()) {
+				transfers = append(transfers, nativeTransfer(source, destination, *destination.Change.Abs()))
+				matched[j], paired = true, true
+				break
+			}
+		}
+		if !paired {
+			unpaired = append(unpaired, source)
+		}
+	}
+	var remaining BalanceSlice
+	for j, destination := range destinations {
+		if !matched[j] {
+			remaining = append(remaining, destination)
+		}
+	}
+	sources, destinations = unpaired, remaining
+	var (
+		sent     = zeroNative.Clone()
+		received = zeroNative.Clone()
+	)
+	for i, j := 0, 0; i < len(sources) && j < len(destinations); {
+		var (
+			source      = sources[i]
+			destination = destinations[j]
+			available   = source.Change.Abs()
+			wanted      = destination.Change.Abs()
+			err         error
+		)
+		if available, err = available.Subtract(*sent); err != nil {
+			return nil, err
+		}
+		if wanted, err = wanted.Subtract(*received); err != nil {
+			return nil, err
+		}
+		change := available
+		if wanted.Less(*available) {
+			change = wanted
+		}
+		transfers = append(transfers, nativeTransfer(source, destination, *change))
+		if sent, err = sent.Add(*change); err != nil {
+			return nil, err
+		}
+		if received, err = received.Add(*change); err != nil {
+			return nil, err
+		}
+		if sent.Equals(*source.Change.Abs()) {
+			i, sent = i+1, zeroNative.Clone()
+		}
+		if received.Equals(*destination.Change.Abs()) {
+			j, received = j+1, zeroNative.Clone()
+		}
+	}
+	return transfers, nil
+}
+
+func nativeTransfer(source, destination Balance, change Value) Transfer {
+	return Transfer{
+		Source:             source.Account,
+		Destination:        destination.Account,
+		SourceBalance:      *newAmount(source.Balance.Clone(), zeroCurrency, zeroAccount),
+		DestinationBalance: *newAmount(destination.Balance.Clone(), zeroCurrency, zeroAccount),
+		Change:             change,
+	}
+}
+
+// rippleTransfer returns the transfer along a trust line and the issuer
+// of the IOUs moved, which is the destination when the source was
+// returning IOUs it held and otherwise the source
+func rippleTransfer(previous, current *RippleState, state LedgerEntryState) (*Transfer, *Account, error) {
+	before := previous.Balance
+	if state == Created && current.Balance != nil {
+		before = current.Balance.ZeroClone()
+	}
+	if before == nil || current.Balance == nil {
+		return nil, nil, nil
+	}
+	if current.LowLimit == nil || current.HighLimit == nil {
+		return nil, nil, fmt.Errorf("RippleState missing LowLimit or HighLimit")
+	}
+	change, err := current.Balance.Subtract(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	if change.IsZero() {
+		return nil, nil, nil
+	}
+	var (
+		currency    = current.Balance.Currency
+		low, high   = current.LowLimit.Issuer, current.HighLimit.Issuer
+		lowBalance  = newAmount(current.Balance.Value.Clone(), currency, high)
+		highBalance = newAmount(current.Balance.Value.Negate(), currency, low)
+		transfer    = &Transfer{Change: *change.Value.Abs()}
+		held        bool
+	)
+	// A rise in the low account's balance moves value from high to low
+	if change.IsNegative() {
+		transfer.Source, transfer.Destination = low, high
+		transfer.SourceBalance, transfer.DestinationBalance = *lowBalance, *highBalance
+		held = !before.IsNegative() && !before.IsZero()
+	} else {
+		transfer.Source, transfer.Destination = high, low
+		transfer.SourceBalance, transfer.DestinationBalance = *highBalance, *lowBalance
+		held = before.IsNegative()
+	}
+	if held {
+		return transfer, &transfer.Destination, nil
+	}
+	return transfer, &transfer.Source, nil
+}
+
+type flowKey struct {
+	account  Account
+	currency Currency
+}
+
+type flow struct {
+	in, out []hop
+}
+
+// attributeFees finds the accounts which value passed through and
+// assigns whatever they kept to the transfers they sent on, unless they
+// traded the currency
+func attributeFees(transfers TransferSlice, hops []hop, traded map[flowKey]bool) error {
+	flows := make(map[flowKey]*flow)
+	get := func(account Account, currency Currency) *flow {
+		key := flowKey{account, currency}
+		if flows[key] == nil {
+			flows[key] = &flow{}
+		}
+		return flows[key]
+	}
+	for _, h := range hops {
+		t := transfers[h.index]
+		currency := t.SourceBalance.Currency
+		get(t.Destination, currency).in = append(get(t.Destination, currency).in, h)
+		get(t.Source, currency).out = append(get(t.Source, currency).out, h)
+	}
+	for key, f := range flows {
+		if len(f.in) == 0 || len(f.out) == 0 || traded[key] {
+			continue
+		}
+		received, issuedIn, err := sumHops(transfers, f.in, key.account)
+		if err != nil {
+			return err
+		}
+		sent, issuedOut, err := sumHops(transfers, f.out, key.account)
+		if err != nil {
+			return err
+		}
+		kept, err := received.Subtract(*sent)
+		if err != nil {
+			return err
+		}
+		if kept.IsZero() {
+			continue
+		}
+		for _, h := range f.out {
+			t := &transfers[h.index]
+			share := kept
+			if len(f.out) > 1 {
+				if share, err = kept.Multiply(t.Change); err != nil {
+					return err
+				}
+				if share, err = share.Divide(*sent); err != nil {
+					return err
+				}
+			}
+			switch {
+			case issuedIn && issuedOut:
+				t.TransitFee = share
+			case hasQuality(f.in, key.account, true):
+				t.QualityIn = share
+			case hasQuality(f.out, key.account, false):
+				t.QualityOut = share
+			}
+		}
+	}
+	return nil
+}
+
+// sumHops totals the hops and reports whether account issued all of them
+func sumHops(transfers TransferSlice, hops []hop, account Account) (*Value, bool, error) {
+	var (
+		total  = transfers[hops[0].index].Change.ZeroClone()
+		issued = true
+		err    error
+	)
+	for _, h := range hops {
+		if total, err = total.Add(transfers[h.index].Change); err != nil {
+			return nil, false, err
+		}
+		issued = issued && h.issuer.Equals(account)
+	}
+	return total, issued, nil
+}
+
+// hasQuality reports whether account set a QualityIn, or a QualityOut,
+// other than parity on any of the lines
+func hasQuality(hops []hop, account Account, in bool) bool {
+	for _, h := range hops {
+		var quality *uint32
+		switch low := h.line.LowLimit.Issuer.Equals(account); {
+		case low && in:
+			quality = h.line.LowQualityIn
+		case low:
+			quality = h.line.LowQualityOut
+		case in:
+			quality = h.line.HighQualityIn
+		default:
+			quality = h.line.HighQualityOut
+		}
+		if quality != nil && *quality != 0 && *quality != 1000000000 {
+			return true
+		}
+	}
+	return false
+}
@@ -334,6 +334,119 @@ func (num Value) Divide(den Value) (*Value, error) {
 	return v, v.canonicalise()
 }
 
+// MulRound multiplies a by b exactly as rippled's mulRound does. The
+// result is native if native is set, regardless of the inputs, and is
+// rounded towards positive infinity if roundUp is set, otherwise
+// towards negative infinity. A positive result which would underflow
+// to zero when rounding up becomes the smallest representable value.
+func (a Value) MulRound(b Value, native, roundUp bool) (*Value, error) {
+	if a.IsZero() || b.IsZero() {
+		return newValue(native, false, 0, 0), nil
+	}
+	if a.IsNative() && b.IsNative() && native {
+		min := min64(a.num, b.num)
+		max := max64(a.num, b.num)
+		if min > maxNativeSqrt || (((max >> 32) * min) > maxNativeDiv) {
+			return nil, fmt.Errorf("Native value overflow: %s*%s", a.debug(), b.debug())
+		}
+		v := newValue(true, a.negative != b.negative, min*max, 0)
+		return v, v.canonicalise()
+	}
+	av, bv, ao, bo := normalise(a, b)
+	negative := a.negative != b.negative
+	// Compute (numerator * denominator) / 10^14 with rounding
+	// 10^16 <= result <= 10^18
+	var rounding uint64
+	if negative != roundUp {
+		rounding = tenTo14m1
+	}
+	m, err := mulDivRound(av, bv, tenTo14, rounding)
+	if err != nil {
+		return nil, fmt.Errorf("Multiply: %s*%s", a.debug(), b.debug())
+	}
+	return roundedValue(native, negative, roundUp, m, ao+bo+14)
+}
+
+// DivRound divides num by den exactly as rippled's divRound does, with
+// the same treatment of native and roundUp as MulRound.
+func (num Value) DivRound(den Value, native, roundUp bool) (*Value, error) {
+	if den.IsZero() {
+		return nil, fmt.Errorf("Division by zero")
+	}
+	if num.IsZero() {
+		return newValue(native, false, 0, 0), nil
+	}
+	av, bv, ao, bo := normalise(num, den)
+	negative := num.negative != den.negative
+	// Compute (numerator * 10^17) / denominator
+	var rounding uint64
+	if negative != roundUp {
+		rounding = bv - 1
+	}
+	d, err := mulDivRound(av, tenTo17, bv, rounding)
+	if err != nil {
+		return nil, fmt.Errorf("Divide: %s/%s", num.debug(), den.debug())
+	}
+	return roundedValue(native, negative, roundUp, d, ao-bo-17)
+}
+
+// mulDivRound returns (a*b + rounding) / c, failing if it exceeds 64 bits
+func mulDivRound(a, b, c, rounding uint64) (uint64, error) {
+	r := big.NewInt(0).SetUint64(a)
+	r.Mul(r, big.NewInt(0).SetUint64(b))
+	r.Add(r, big.NewInt(0).SetUint64(rounding))
+	r.Div(r, big.NewInt(0).SetUint64(c))
+	if r.BitLen() > 64 {
+		return 0, fmt.Errorf("Overflow")
+	}
+	return r.Uint64(), nil
+}
+
+func roundedValue(native, negative, roundUp bool, num uint64, offset int64) (*Value, error) {
+	// Rounding away from zero has to happen before canonicalise
+	// truncates, which rounds towards zero
+	if negative != roundUp {
+		num, offset = canonicaliseRound(native, num, offset)
+	}
+	v := newValue(native, negative, num, offset)
+	if err := v.canonicalise(); err != nil {
+		return nil, err
+	}
+	if roundUp && !negative && v.IsZero() {
+		if native {
+			return newValue(true, false, 1, 0), nil
+		}
+		return newValue(false, false, minValue, minOffset), nil
+	}
+	return v, nil
+}
+
+// canonicaliseRound brings num within range, rounding up all but the
+// final digit which canonicalise will truncate. The native branch
+// reproduces rippled's, including its treatment of a single division.
+func canonicaliseRound(native bool, num uint64, offset int64) (uint64, int64) {
+	switch {
+	case native && offset < 0:
+		loops := 0
+		for ; offset < -1; offset++ {
+			num /= 10
+			loops++
+		}
+		if loops >= 2 {
+			num += 9
+		} else {
+			num += 10
+		}
+		return num / 10, offset + 1
+	case !native && num > maxValue:
+		for ; num > 10*maxValue; offset++ {
+			num /= 10
+		}
+		return (num + 9) / 10, offset + 1
+	}
+	return num, offset
+}
+
 // Ratio returns the ratio a/b. ICC are interpreted at face value rather than drips.
 // The result of Ratio is always a non-native Value for additional precision.
 func (a Value) Ratio(b Value) (*Value, error) {
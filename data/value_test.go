@@ -305,6 +305,63 @@ func (s *ValueSuite) TestValue(c *C) {
 	valueTests.Test(c)
 }
 
+// Results worked out by hand from thirds, which never divide exactly,
+// and from drops, where a fraction of a drop must go one way or the other
+var roundTests = TestSlice{
+	{divRoundCheck("1", "3", false, false), Equals, "0.3333333333333333", "divRound 1/3 down"},
+	{divRoundCheck("1", "3", false, true), Equals, "0.3333333333333334", "divRound 1/3 up"},
+	{divRoundCheck("2", "3", false, false), Equals, "0.6666666666666666", "divRound 2/3 down"},
+	{divRoundCheck("2", "3", false, true), Equals, "0.6666666666666667", "divRound 2/3 up"},
+	{divRoundCheck("-1", "3", false, false), Equals, "-0.3333333333333334", "divRound -1/3 down"},
+	{divRoundCheck("-1", "3", false, true), Equals, "-0.3333333333333333", "divRound -1/3 up"},
+	{divRoundCheck("1", "4", false, true), Equals, "0.25", "divRound exact"},
+	{divRoundCheck("n1", "3", true, false), Equals, "0", "divRound 1 drop/3 down"},
+	{divRoundCheck("n1", "3", true, true), Equals, "0.000001", "divRound 1 drop/3 up"},
+	{divRoundCheck("n10", "3", true, false), Equals, "0.000003", "divRound 10 drops/3 down"},
+	{divRoundCheck("n10", "3", true, true), Equals, "0.000004", "divRound 10 drops/3 up"},
+	{divRoundCheck("n10", "n3", false, true), Equals, "3.333333333333334", "divRound drops into IOU"},
+	{divRoundCheck("1e-81", "1e15", false, false), Equals, "0", "divRound underflow down"},
+	{divRoundCheck("1e-81", "1e15", false, true), Equals, "1e-81", "divRound underflow up"},
+	{ErrorCheck(valueCheck("1").DivRound(*valueCheck("0"), false, true)), ErrorMatches, "Division by zero", "divRound by zero"},
+
+	{mulRoundCheck("0.3333333333333333", "3", false, false), Equals, "0.9999999999999999", "mulRound 1/3*3 down"},
+	{mulRoundCheck("0.3333333333333334", "3", false, true), Equals, "1.000000000000001", "mulRound 1/3*3 up"},
+	{mulRoundCheck("-0.3333333333333334", "3", false, false), Equals, "-1.000000000000001", "mulRound -1/3*3 down"},
+	{mulRoundCheck("-0.3333333333333334", "3", false, true), Equals, "-1", "mulRound -1/3*3 up"},
+	{mulRoundCheck("1.5", "2", false, true), Equals, "3", "mulRound exact"},
+	{mulRoundCheck("n1", "0.5", true, false), Equals, "0", "mulRound 1 drop*0.5 down"},
+	{mulRoundCheck("n1", "0.5", true, true), Equals, "0.000001", "mulRound 1 drop*0.5 up"},
+	{mulRoundCheck("n3", "0.5", true, false), Equals, "0.000001", "mulRound 3 drops*0.5 down"},
+	{mulRoundCheck("n3", "0.5", true, true), Equals, "0.000002", "mulRound 3 drops*0.5 up"},
+	{mulRoundCheck("n3", "n4", true, true), Equals, "0.000012", "mulRound drops*drops"},
+	{mulRoundCheck("n3", "n4", false, true), Equals, "12", "mulRound drops*drops into IOU"},
+	{mulRoundCheck("1e-81", "1e-81", false, false), Equals, "0", "mulRound underflow down"},
+	{mulRoundCheck("1e-81", "1e-81", false, true), Equals, "1e-81", "mulRound underflow up"},
+	{mulRoundCheck("0", "3", false, true), Equals, "0", "mulRound zero"},
+	{ErrorCheck(valueCheck("n3000000001").MulRound(*valueCheck("n3000000001"), true, true)), ErrorMatches, "Native value overflow: .*", "mulRound native overflow"},
+	{ErrorCheck(valueCheck("1e80").MulRound(*valueCheck("1e80"), false, true)), ErrorMatches, "Value overflow: .*", "mulRound overflow"},
+}
+
+func mulRoundCheck(a, b string, native, roundUp bool) string {
+	v, err := valueCheck(a).MulRound(*valueCheck(b), native, roundUp)
+	if err != nil {
+		panic(err)
+	}
+	return v.String()
+}
+
+func divRoundCheck(a, b string, native, roundUp bool) string {
+	v, err := valueCheck(a).DivRound(*valueCheck(b), native, roundUp)
+	if err != nil {
+		panic(err)
+	}
+	return v.String()
+}
+
+func (s *ValueSuite) TestRound(c *C) {
+	roundTests.Test(c)
+}
+
 func checkValBinaryMarshal(v1 *Value) *Value {
 	var b []byte
 	var err error
@@ -0,0 +1,472 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wangch/ripple/data"
+	"github.com/wangch/ripple/storage"
+)
+
+// BookOffer is an offer in an order book together with the part of it
+// which its owner can currently fund
+type BookOffer struct {
+	data.Offer
+	Index           data.Hash256
+	TakerGetsFunded *data.Amount
+	TakerPaysFunded *data.Amount
+}
+
+func (o BookOffer) IsFunded() bool {
+	return o.TakerGetsFunded != nil && !o.TakerGetsFunded.IsZero()
+}
+
+// Offers are the two sides of an order book. Asks sell the pair's Base
+// and Bids buy it, each ordered by quality with the best first and then
+// by the time they were placed.
+type Offers struct {
+	Asks []BookOffer
+	Bids []BookOffer
+}
+
+// Inverse returns the book as seen from the inverse pair
+func (o Offers) Inverse() Offers {
+	return Offers{Asks: o.Bids, Bids: o.Asks}
+}
+
+type hashSlice []data.Hash256
+
+func (s hashSlice) Len() int           { return len(s) }
+func (s hashSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s hashSlice) Less(i, j int) bool { return s[i].Compare(s[j]) < 0 }
+
+// bookSide returns the pair of the book an offer belongs to and whether
+// it is an ask in that book
+func bookSide(offer *data.Offer) (data.CurrencyPair, bool) {
+	pair := data.NewCurrencyPair(offer.TakerGets.Issue(), offer.TakerPays.Issue())
+	return pair, pair.Base.Equals(offer.TakerGets.Issue())
+}
+
+// Book returns the offers for a pair in either orientation
+func (state *LedgerState) Book(pair data.CurrencyPair) Offers {
+	if offers, ok := state.Books[pair]; ok {
+		return offers
+	}
+	return state.Books[pair.Inverse()].Inverse()
+}
+
+// FillBooks loads every offer in the account state into Books, walking
+// each book directory in order of quality, and calculates how much of
+// each offer is funded
+func (state *LedgerState) FillBooks() error {
+	if err := state.AccountState.Fill(); err != nil {
+		return err
+	}
+	var roots hashSlice
+	seen := make(map[data.Hash256]bool)
+	err := state.AccountState.Walk(func(key data.Hash256, node *RadixNode) error {
+		if offer, ok := node.Node.(*data.Offer); ok && offer.BookDirectory != nil && !seen[*offer.BookDirectory] {
+			seen[*offer.BookDirectory] = true
+			roots = append(roots, *offer.BookDirectory)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Indexes of a book's directories differ only in their quality
+	sort.Sort(roots)
+	state.Books = make(map[data.CurrencyPair]Offers)
+	state.offerBooks = make(map[data.Hash256]data.CurrencyPair)
+	state.involved = make(map[data.Account]map[data.CurrencyPair]int)
+	for _, root := range roots {
+		err := data.WalkDirectory(state, root, func(index data.Hash256, le data.LedgerEntry) error {
+			offer, ok := le.(*data.Offer)
+			if !ok {
+				return fmt.Errorf("Not an offer: %s is %s", index, le.GetLedgerEntryType())
+			}
+			state.insertOffer(index, offer)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for pair := range state.Books {
+		if err := state.fundBook(pair); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyTransaction updates Books, and the entries returned by
+// GetLedgerEntry, with the nodes affected by a validated transaction.
+// Transactions must be applied in the order they were validated.
+func (state *LedgerState) ApplyTransaction(txm *data.TransactionWithMetaData) error {
+	if state.live == nil {
+		state.live = make(map[data.Hash256]data.LedgerEntry)
+	}
+	touched := make(map[data.Account]bool)
+	all := false
+	for _, effect := range txm.MetaData.AffectedNodes {
+		node, final, _, action := effect.AffectedNode()
+		if node.LedgerIndex == nil {
+			return fmt.Errorf("Affected %s has no index", node.LedgerEntryType)
+		}
+		index := *node.LedgerIndex
+		fields := node.FinalFields
+		if action == data.Created {
+			fields = node.NewFields
+		}
+		switch {
+		case action == data.Deleted:
+			state.live[index] = nil
+		case fields == nil:
+			// Nothing is known of the entry but that it changed
+			continue
+		default:
+			state.live[index] = final
+		}
+		switch le := final.(type) {
+		case *data.Offer:
+			// A partially filled offer keeps its place in the book
+			switch action {
+			case data.Created:
+				state.insertOffer(index, le)
+			case data.Modified:
+				state.updateOffer(index, le)
+			case data.Deleted:
+				state.removeOffer(index)
+			}
+			if le.Account != nil {
+				touched[*le.Account] = true
+			}
+		case *data.AccountRoot:
+			if le.Account != nil {
+				touched[*le.Account] = true
+			}
+		case *data.RippleState:
+			if le.LowLimit != nil && le.HighLimit != nil {
+				touched[le.LowLimit.Issuer], touched[le.HighLimit.Issuer] = true, true
+			}
+			if state.lines != nil {
+				if action == data.Deleted {
+					state.lines.remove(index)
+				} else {
+					state.lines.set(index, le)
+				}
+			}
+		case *data.FeeSettings:
+			// The reserve affects every offer selling ICC
+			all = true
+		}
+	}
+	pairs := make(map[data.CurrencyPair]bool)
+	for account := range touched {
+		for pair := range state.involved[account] {
+			pairs[pair] = true
+		}
+	}
+	for pair := range state.Books {
+		if all || pairs[pair] {
+			if err := state.fundBook(pair); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// involve counts an offer in its book for its owner and the issuer of
+// what it pays out, either of whom can change how much of it is funded
+func (state *LedgerState) involve(pair data.CurrencyPair, offer *data.Offer, count int) {
+	if state.involved == nil {
+		state.involved = make(map[data.Account]map[data.CurrencyPair]int)
+	}
+	for _, account := range []data.Account{*offer.Account, offer.TakerGets.Issuer} {
+		books := state.involved[account]
+		if books == nil {
+			books = make(map[data.CurrencyPair]int)
+			state.involved[account] = books
+		}
+		if books[pair] += count; books[pair] <= 0 {
+			delete(books, pair)
+		}
+	}
+}
+
+// findOffer returns the book holding the offer with index, whether it
+// is an ask and its position on that side, or false if it is not in Books
+func (state *LedgerState) findOffer(index data.Hash256) (data.CurrencyPair, bool, int, bool) {
+	pair, ok := state.offerBooks[index]
+	if !ok {
+		return pair, false, 0, false
+	}
+	offers := state.Books[pair]
+	for i := range offers.Asks {
+		if offers.Asks[i].Index == index {
+			return pair, true, i, true
+		}
+	}
+	for i := range offers.Bids {
+		if offers.Bids[i].Index == index {
+			return pair, false, i, true
+		}
+	}
+	return pair, false, 0, false
+}
+
+// insertOffer adds an offer after those of the same or better quality
+func (state *LedgerState) insertOffer(index data.Hash256, offer *data.Offer) {
+	pair, ask := bookSide(offer)
+	offers := state.Books[pair]
+	side := &offers.Bids
+	if ask {
+		side = &offers.Asks
+	}
+	quality := offer.Quality()
+	i := sort.Search(len(*side), func(i int) bool {
+		return quality.Less((*side)[i].Quality())
+	})
+	*side = append(*side, BookOffer{})
+	copy((*side)[i+1:], (*side)[i:])
+	(*side)[i] = BookOffer{Offer: *offer, Index: index}
+	state.Books[pair] = offers
+	if state.offerBooks == nil {
+		state.offerBooks = make(map[data.Hash256]data.CurrencyPair)
+	}
+	state.offerBooks[index] = pair
+	state.involve(pair, offer, 1)
+}
+
+// updateOffer replaces the offer with index in Books, leaving it where
+// it was placed
+func (state *LedgerState) updateOffer(index data.Hash256, offer *data.Offer) {
+	pair, ask, i, ok := state.findOffer(index)
+	if !ok {
+		return
+	}
+	side := state.Books[pair].Bids
+	if ask {
+		side = state.Books[pair].Asks
+	}
+	side[i].Offer = *offer
+}
+
+// removeOffer removes the offer with index from Books
+func (state *LedgerState) removeOffer(index data.Hash256) {
+	pair, ask, i, ok := state.findOffer(index)
+	if !ok {
+		return
+	}
+	offers := state.Books[pair]
+	side := &offers.Bids
+	if ask {
+		side = &offers.Asks
+	}
+	state.involve(pair, &(*side)[i].Offer, -1)
+	*side = append((*side)[:i], (*side)[i+1:]...)
+	if len(offers.Asks) == 0 && len(offers.Bids) == 0 {
+		delete(state.Books, pair)
+	} else {
+		state.Books[pair] = offers
+	}
+	delete(state.offerBooks, index)
+}
+
+func (state *LedgerState) fundBook(pair data.CurrencyPair) error {
+	offers := state.Books[pair]
+	if err := state.fund(offers.Asks); err != nil {
+		return err
+	}
+	return state.fund(offers.Bids)
+}
+
+// fund sets the funded amounts of the offers on one side of a book as
+// rippled's book_offers does. An owner's earlier offers use up their
+// funds first, and the transfer fee the owner would pay is set aside.
+func (state *LedgerState) fund(offers []BookOffer) error {
+	remaining := make(map[data.Account]*data.Value)
+	for i := range offers {
+		o := &offers[i]
+		gets, pays := o.TakerGets, o.TakerPays
+		funds, ok := remaining[*o.Account]
+		if !ok {
+			var err error
+			if funds, err = state.ownerFunds(*o.Account, *gets); err != nil {
+				return err
+			}
+		}
+		if funds == nil {
+			// Issuers fund their own offers
+			o.TakerGetsFunded, o.TakerPaysFunded = gets.Clone(), pays.Clone()
+			continue
+		}
+		rate, err := state.transferRate(*o.Account, *gets)
+		if err != nil {
+			return err
+		}
+		limit := funds
+		if rate != nil {
+			if limit, err = funds.DivRound(*rate, gets.IsNative(), false); err != nil {
+				return err
+			}
+		}
+		if !limit.Less(*gets.Value) {
+			o.TakerGetsFunded, o.TakerPaysFunded = gets.Clone(), pays.Clone()
+		} else {
+			quality, err := offerQuality(&o.Offer)
+			if err != nil {
+				return err
+			}
+			paysFunded, err := limit.MulRound(*quality, pays.IsNative(), false)
+			if err != nil {
+				return err
+			}
+			if pays.Value.Less(*paysFunded) {
+				paysFunded = pays.Value
+			}
+			o.TakerGetsFunded = &data.Amount{Value: limit, Currency: gets.Currency, Issuer: gets.Issuer}
+			o.TakerPaysFunded = &data.Amount{Value: paysFunded, Currency: pays.Currency, Issuer: pays.Issuer}
+		}
+		spent := o.TakerGetsFunded.Value
+		if rate != nil {
+			if spent, err = spent.MulRound(*rate, gets.IsNative(), true); err != nil {
+				return err
+			}
+		}
+		if funds.Less(*spent) {
+			remaining[*o.Account] = funds.ZeroClone()
+		} else if remaining[*o.Account], err = funds.Subtract(*spent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offerQuality returns the ratio of TakerPays to TakerGets the offer was
+// placed at, with ICC in drips
+func offerQuality(offer *data.Offer) (*data.Value, error) {
+	quality := offer.Quality()
+	if quality == 0 {
+		var err error
+		if quality, err = data.NewExchangeRate(offer.TakerPays, offer.TakerGets); err != nil {
+			return nil, err
+		}
+	}
+	return quality.Value()
+}
+
+// ownerFunds returns the amount of the currency of gets which owner
+// holds and can spend, which is nil when owner issued it
+func (state *LedgerState) ownerFunds(owner data.Account, gets data.Amount) (*data.Value, error) {
+	if gets.IsNative() {
+		root, err := state.accountRoot(owner)
+		if err == storage.ErrNotFound {
+			return gets.Value.ZeroClone(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		reserve, err := state.reserve()
+		if err != nil {
+			return nil, err
+		}
+		return root.Spendable(*reserve)
+	}
+	if owner.Equals(gets.Issuer) {
+		return nil, nil
+	}
+	issuer, err := state.accountRoot(gets.Issuer)
+	switch {
+	case err == storage.ErrNotFound:
+		return gets.Value.ZeroClone(), nil
+	case err != nil:
+		return nil, err
+	case issuer.Flags != nil && *issuer.Flags&data.LsGlobalFreeze != 0:
+		return gets.Value.ZeroClone(), nil
+	}
+	index, err := data.GetRippleStateIndex(owner, gets.Issuer, gets.Currency)
+	if err != nil {
+		return nil, err
+	}
+	le, err := state.GetLedgerEntry(*index)
+	if err == storage.ErrNotFound {
+		return gets.Value.ZeroClone(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	line, ok := le.(*data.RippleState)
+	if !ok {
+		return nil, fmt.Errorf("Not a RippleState: %s", index)
+	}
+	// Positive balances are held by the low account and the issuer
+	// freezes a line on its own side
+	balance, freeze := line.Balance.Value, data.LsHighFreeze
+	if line.HighLimit.Issuer.Equals(owner) {
+		balance, freeze = balance.Negate(), data.LsLowFreeze
+	}
+	if balance.IsNegative() || (line.Flags != nil && *line.Flags&freeze != 0) {
+		return gets.Value.ZeroClone(), nil
+	}
+	return balance, nil
+}
+
+// transferRate returns the fee owner pays to deliver gets as a ratio,
+// or nil when there is none
+func (state *LedgerState) transferRate(owner data.Account, gets data.Amount) (*data.Value, error) {
+	if gets.IsNative() || owner.Equals(gets.Issuer) {
+		return nil, nil
+	}
+	return state.issuerRate(gets.Issuer)
+}
+
+// issuerRate returns the TransferRate of issuer as a ratio, or nil
+// when it charges no fee
+func (state *LedgerState) issuerRate(issuer data.Account) (*data.Value, error) {
+	root, err := state.accountRoot(issuer)
+	if err == storage.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if root.TransferRate == nil || *root.TransferRate == 0 || *root.TransferRate == 1000000000 {
+		return nil, nil
+	}
+	return data.NewNonNativeValue(int64(*root.TransferRate), -9)
+}
+
+func (state *LedgerState) accountRoot(account data.Account) (*data.AccountRoot, error) {
+	index, err := data.GetAccountRootIndex(account)
+	if err != nil {
+		return nil, err
+	}
+	le, err := state.GetLedgerEntry(*index)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := le.(*data.AccountRoot)
+	if !ok {
+		return nil, fmt.Errorf("Not an AccountRoot: %s", index)
+	}
+	return root, nil
+}
+
+func (state *LedgerState) reserve() (*data.Reserve, error) {
+	index, err := data.GetFeeIndex()
+	if err != nil {
+		return nil, err
+	}
+	le, err := state.GetLedgerEntry(*index)
+	if err != nil {
+		return nil, fmt.Errorf("No FeeSettings: %s", err)
+	}
+	fees, ok := le.(*data.FeeSettings)
+	if !ok {
+		return nil, fmt.Errorf("Not a FeeSettings: %s", index)
+	}
+	return fees.Reserve()
+}
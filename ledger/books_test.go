@@ -0,0 +1,306 @@
+package ledger
+
+import (
+	"github.com/wangch/ripple/data"
+	"github.com/wangch/ripple/storage/memdb"
+	. "gopkg.in/check.v1"
+)
+
+type BooksSuite struct{}
+
+var _ = Suite(&BooksSuite{})
+
+var (
+	gatewayAddress = "iMWUykAmNQDaM9poSes8VLDZDDKEbmo7MX"
+	aliceAddress   = "iG1QQv2nh2gi7RCZ1P8YYcBUKCCN633jCn"
+	bobAddress     = "iNPRNzBB92BVpAhhZi4rXDTveCgV5Pofm9"
+)
+
+func booksAccount(c *C, address string) data.Account {
+	account, err := data.NewAccountFromAddress(address)
+	c.Assert(err, IsNil)
+	return *account
+}
+
+func booksAmount(c *C, s string) *data.Amount {
+	amount, err := data.NewAmount(s)
+	c.Assert(err, IsNil)
+	return amount
+}
+
+// synthetic builds a LedgerState holding entries at the indexes
+// derived from their fields
+func synthetic(c *C, entries ...data.LedgerEntry) *LedgerState {
+	leaves := make(map[data.Hash256]data.LedgerEntry)
+	for _, le := range entries {
+		index, err := data.LedgerIndex(le)
+		c.Assert(err, IsNil)
+		leaves[*index] = le
+	}
+	return syntheticAt(c, leaves)
+}
+
+// syntheticAt builds a LedgerState in a memory database holding each
+// leaf at its index, with the node hashes rippled would give them
+func syntheticAt(c *C, leaves map[data.Hash256]data.LedgerEntry) *LedgerState {
+	db := memdb.NewEmptyMemoryDB()
+	var indexes []data.Hash256
+	for index := range leaves {
+		indexes = append(indexes, index)
+	}
+	var build func(depth int, indexes []data.Hash256) data.Hash256
+	build = func(depth int, indexes []data.Hash256) data.Hash256 {
+		if len(indexes) == 1 {
+			le := leaves[indexes[0]]
+			hash, err := data.LeafHash(le, indexes[0])
+			c.Assert(err, IsNil)
+			*le.GetHash() = hash
+			c.Assert(db.Insert(le), IsNil)
+			return hash
+		}
+		var branches [16][]data.Hash256
+		for _, index := range indexes {
+			nibble := index[depth/2] >> 4
+			if depth%2 == 1 {
+				nibble = index[depth/2] & 0xF
+			}
+			branches[nibble] = append(branches[nibble], index)
+		}
+		inner := &data.InnerNode{Type: data.NT_ACCOUNT_NODE}
+		for i, branch := range branches {
+			if len(branch) > 0 {
+				inner.Children[i] = build(depth+1, branch)
+			}
+		}
+		var err error
+		inner.Id, err = data.NodeId(inner)
+		c.Assert(err, IsNil)
+		c.Assert(db.Insert(inner), IsNil)
+		return inner.Id
+	}
+	return &LedgerState{
+		AccountState: NewRadixMap(build(0, indexes), db),
+		Books:        make(map[data.CurrencyPair]Offers),
+	}
+}
+
+func feeSettings(base, increment uint32) *data.FeeSettings {
+	return &data.FeeSettings{ReserveBase: &base, ReserveIncrement: &increment}
+}
+
+func booksAccountRoot(account data.Account, balance *data.Amount, owned uint32) *data.AccountRoot {
+	le := data.LedgerEntryFactory[data.ACCOUNT_ROOT]().(*data.AccountRoot)
+	le.Account, le.Balance, le.OwnerCount = &account, balance.Value, &owned
+	return le
+}
+
+// trustLine returns the RippleState of holder with balance issued by its issuer
+func trustLine(holder data.Account, balance *data.Amount) *data.RippleState {
+	le := data.LedgerEntryFactory[data.RIPPLE_STATE]().(*data.RippleState)
+	low, high := holder, balance.Issuer
+	value := balance.Value
+	if high.Less(low) {
+		low, high, value = high, low, value.Negate()
+	}
+	le.LowLimit = &data.Amount{Value: balance.Value.ZeroClone(), Currency: balance.Currency, Issuer: low}
+	le.HighLimit = &data.Amount{Value: balance.Value.ZeroClone(), Currency: balance.Currency, Issuer: high}
+	le.Balance = &data.Amount{Value: value, Currency: balance.Currency}
+	return le
+}
+
+// bookOffer returns an offer placed in a book directory at its quality
+func bookOffer(c *C, account data.Account, sequence uint32, pays, gets *data.Amount) *data.Offer {
+	book, err := data.GetBookIndex(data.Hash160(pays.Currency), data.Hash160(gets.Currency), data.Hash160(pays.Issuer), data.Hash160(gets.Issuer))
+	c.Assert(err, IsNil)
+	rate, err := data.NewExchangeRate(pays, gets)
+	c.Assert(err, IsNil)
+	le := data.LedgerEntryFactory[data.OFFER]().(*data.Offer)
+	le.Account, le.Sequence, le.TakerPays, le.TakerGets = &account, &sequence, pays, gets
+	le.BookDirectory = data.GetQualityIndex(*book, rate)
+	return le
+}
+
+// bookDirectories returns a single page directory for each quality of the offers
+func bookDirectories(c *C, offers ...*data.Offer) []data.LedgerEntry {
+	pages := make(map[data.Hash256]*data.Directory)
+	var dirs []data.LedgerEntry
+	for _, offer := range offers {
+		index, err := data.LedgerIndex(offer)
+		c.Assert(err, IsNil)
+		dir, ok := pages[*offer.BookDirectory]
+		if !ok {
+			dir = data.LedgerEntryFactory[data.DIRECTORY]().(*data.Directory)
+			dir.RootIndex, dir.Indexes = offer.BookDirectory, &data.Vector256{}
+			pages[*offer.BookDirectory] = dir
+			dirs = append(dirs, dir)
+		}
+		*dir.Indexes = append(*dir.Indexes, *index)
+	}
+	return dirs
+}
+
+func checkFunded(c *C, offer BookOffer, gets, pays string) {
+	c.Check(offer.TakerGetsFunded.Value.String(), Equals, gets, Commentf("%d", *offer.Sequence))
+	c.Check(offer.TakerPaysFunded.Value.String(), Equals, pays, Commentf("%d", *offer.Sequence))
+}
+
+// booksFixture returns a state with a gateway charging a 25% transfer
+// fee, alice selling her 100 USD in two offers, the gateway selling USD
+// and bob buying USD with ICC
+func booksFixture(c *C) (*LedgerState, []*data.Offer) {
+	gateway := booksAccount(c, gatewayAddress)
+	alice := booksAccount(c, aliceAddress)
+	bob := booksAccount(c, bobAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	icc := func(v string) *data.Amount { return booksAmount(c, v+"/ICC") }
+
+	gatewayRoot := booksAccountRoot(gateway, icc("1000"), 0)
+	rate := uint32(1250000000)
+	gatewayRoot.TransferRate = &rate
+	offers := []*data.Offer{
+		bookOffer(c, alice, 2, icc("200"), usd("80")),
+		bookOffer(c, gateway, 1, icc("30"), usd("10")),
+		bookOffer(c, alice, 1, icc("80"), usd("40")),
+		bookOffer(c, bob, 1, usd("20"), icc("50")),
+	}
+	entries := []data.LedgerEntry{
+		feeSettings(20000000, 5000000),
+		gatewayRoot,
+		booksAccountRoot(alice, icc("100"), 3),
+		booksAccountRoot(bob, icc("60"), 1),
+		trustLine(alice, usd("100")),
+	}
+	for _, offer := range offers {
+		entries = append(entries, offer)
+	}
+	state := synthetic(c, append(entries, bookDirectories(c, offers...)...)...)
+	c.Assert(state.FillBooks(), IsNil)
+	return state, offers
+}
+
+func (s *BooksSuite) TestBooks(c *C) {
+	gateway := booksAccount(c, gatewayAddress)
+	alice := booksAccount(c, aliceAddress)
+	bob := booksAccount(c, bobAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	icc := func(v string) *data.Amount { return booksAmount(c, v+"/ICC") }
+	state, offers := booksFixture(c)
+
+	pair := data.NewCurrencyPair(usd("1").Issue(), icc("1").Issue())
+	c.Assert(state.Books, HasLen, 1)
+	book := state.Book(pair)
+	c.Assert(book.Asks, HasLen, 3)
+	c.Assert(book.Bids, HasLen, 1)
+	// The transfer fee leaves alice 80 USD to sell, most of it in her
+	// better offer
+	c.Check(*book.Asks[0].Account, Equals, alice)
+	checkFunded(c, book.Asks[0], "40", "80")
+	c.Check(*book.Asks[1].Account, Equals, alice)
+	checkFunded(c, book.Asks[1], "40", "100")
+	c.Check(*book.Asks[2].Account, Equals, gateway)
+	checkFunded(c, book.Asks[2], "10", "30")
+	// Bob can spend 35 of his 60 ICC
+	checkFunded(c, book.Bids[0], "35", "14")
+	c.Check(state.Book(pair.Inverse()).Asks[0].Index, Equals, book.Bids[0].Index)
+
+	// Alice sends 40 USD, bob places a worse bid and the gateway's offer is taken
+	aliceLine := trustLine(alice, usd("60"))
+	aliceLineIndex, err := data.LedgerIndex(aliceLine)
+	c.Assert(err, IsNil)
+	bobOffer := bookOffer(c, bob, 2, usd("5"), icc("10"))
+	bobOfferIndex, err := data.LedgerIndex(bobOffer)
+	c.Assert(err, IsNil)
+	gatewayOfferIndex, err := data.LedgerIndex(offers[1])
+	c.Assert(err, IsNil)
+	txm := &data.TransactionWithMetaData{MetaData: data.MetaData{AffectedNodes: data.NodeEffects{
+		{ModifiedNode: &data.AffectedNode{LedgerEntryType: data.RIPPLE_STATE, LedgerIndex: aliceLineIndex, FinalFields: aliceLine}},
+		{CreatedNode: &data.AffectedNode{LedgerEntryType: data.OFFER, LedgerIndex: bobOfferIndex, NewFields: bobOffer}},
+		{DeletedNode: &data.AffectedNode{LedgerEntryType: data.OFFER, LedgerIndex: gatewayOfferIndex, FinalFields: offers[1]}},
+	}}}
+	c.Assert(state.ApplyTransaction(txm), IsNil)
+
+	le, err := state.GetLedgerEntry(*aliceLineIndex)
+	c.Assert(err, IsNil)
+	c.Check(le.(*data.RippleState).Balance.Value.String(), Equals, "60")
+	_, err = state.GetLedgerEntry(*gatewayOfferIndex)
+	c.Check(err, NotNil)
+
+	book = state.Book(pair)
+	c.Assert(book.Asks, HasLen, 2)
+	checkFunded(c, book.Asks[0], "40", "80")
+	checkFunded(c, book.Asks[1], "8", "20")
+	c.Assert(book.Bids, HasLen, 2)
+	checkFunded(c, book.Bids[0], "35", "14")
+	c.Check(book.Bids[1].Index, Equals, *bobOfferIndex)
+	c.Check(book.Bids[1].IsFunded(), Equals, false)
+}
+
+func (s *BooksSuite) TestModifiedOffer(c *C) {
+	gateway := booksAccount(c, gatewayAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	icc := func(v string) *data.Amount { return booksAmount(c, v+"/ICC") }
+	first := bookOffer(c, gateway, 1, icc("20"), usd("10"))
+	second := bookOffer(c, gateway, 2, icc("40"), usd("20"))
+	state := synthetic(c, append([]data.LedgerEntry{
+		feeSettings(20000000, 5000000),
+		booksAccountRoot(gateway, icc("1000"), 2),
+		first,
+		second,
+	}, bookDirectories(c, first, second)...)...)
+	c.Assert(state.FillBooks(), IsNil)
+	pair := data.NewCurrencyPair(usd("1").Issue(), icc("1").Issue())
+	firstIndex, err := data.LedgerIndex(first)
+	c.Assert(err, IsNil)
+	c.Assert(state.Book(pair).Asks, HasLen, 2)
+	c.Check(state.Book(pair).Asks[0].Index, Equals, *firstIndex)
+
+	// Half of the first offer is taken and it keeps its place
+	filled := *first
+	filled.TakerPays, filled.TakerGets = icc("10"), usd("5")
+	txm := &data.TransactionWithMetaData{MetaData: data.MetaData{AffectedNodes: data.NodeEffects{
+		{ModifiedNode: &data.AffectedNode{LedgerEntryType: data.OFFER, LedgerIndex: firstIndex, FinalFields: &filled}},
+	}}}
+	c.Assert(state.ApplyTransaction(txm), IsNil)
+	book := state.Book(pair)
+	c.Assert(book.Asks, HasLen, 2)
+	c.Check(book.Asks[0].Index, Equals, *firstIndex)
+	checkFunded(c, book.Asks[0], "5", "10")
+	checkFunded(c, book.Asks[1], "20", "40")
+}
+
+func (s *BooksSuite) TestNodesWithoutFields(c *C) {
+	gateway := booksAccount(c, gatewayAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	icc := func(v string) *data.Amount { return booksAmount(c, v+"/ICC") }
+	first := bookOffer(c, gateway, 1, icc("20"), usd("10"))
+	second := bookOffer(c, gateway, 2, icc("40"), usd("20"))
+	state := synthetic(c, append([]data.LedgerEntry{
+		feeSettings(20000000, 5000000),
+		booksAccountRoot(gateway, icc("1000"), 2),
+		first,
+		second,
+	}, bookDirectories(c, first, second)...)...)
+	c.Assert(state.FillBooks(), IsNil)
+	pair := data.NewCurrencyPair(usd("1").Issue(), icc("1").Issue())
+	firstIndex, err := data.LedgerIndex(first)
+	c.Assert(err, IsNil)
+	secondIndex, err := data.LedgerIndex(second)
+	c.Assert(err, IsNil)
+
+	// The first offer is modified and the second deleted, with neither
+	// showing its fields
+	txm := &data.TransactionWithMetaData{MetaData: data.MetaData{AffectedNodes: data.NodeEffects{
+		{ModifiedNode: &data.AffectedNode{LedgerEntryType: data.OFFER, LedgerIndex: firstIndex}},
+		{DeletedNode: &data.AffectedNode{LedgerEntryType: data.OFFER, LedgerIndex: secondIndex}},
+	}}}
+	c.Assert(state.ApplyTransaction(txm), IsNil)
+	le, err := state.GetLedgerEntry(*firstIndex)
+	c.Assert(err, IsNil)
+	c.Check(le.(*data.Offer).TakerPays.String(), Equals, "20/ICC")
+	_, err = state.GetLedgerEntry(*secondIndex)
+	c.Check(err, NotNil)
+	book := state.Book(pair)
+	c.Assert(book.Asks, HasLen, 1)
+	c.Check(book.Asks[0].Index, Equals, *firstIndex)
+	checkFunded(c, book.Asks[0], "10", "20")
+}
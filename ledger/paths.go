@@ -0,0 +1,575 @@
+package ledger
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wangch/ripple/data"
+)
+
+// PathAlternative is a way of paying a destination amount with one
+// source currency, as in the server's ripple_path_find alternatives
+type PathAlternative struct {
+	SourceAmount data.Amount
+	Paths        data.PathSet
+}
+
+// searchLevel is the level the server searches to for ripple_path_find
+// by default, and maxPaths the most paths it returns for each source
+// currency
+const (
+	searchLevel = 7
+	maxPaths    = 4
+)
+
+// A pathType spells out the nodes of the paths to search for as the
+// server does: s is the source, a an account rippled through, b any
+// order book, x an order book to ICC, f an order book to the currency
+// delivered and d the destination. Each type is searched for from a
+// search level, the most useful first.
+type pathType struct {
+	level int
+	nodes string
+}
+
+type paymentType int
+
+const (
+	iccToIcc paymentType = iota
+	iccToIssued
+	issuedToIcc
+	issuedToSame
+	issuedToIssued
+)
+
+// pathTypes are the types of path the server searches for each type of
+// payment, besides the path implied by the payment itself
+var pathTypes = map[paymentType][]pathType{
+	iccToIcc: nil,
+	iccToIssued: {
+		{1, "sfd"}, {3, "sfad"}, {5, "sfaad"}, {6, "sbfd"},
+		{8, "sbafd"}, {9, "sbfad"}, {10, "sbafad"},
+	},
+	issuedToIcc: {
+		{1, "sxd"}, {2, "saxd"}, {6, "saaxd"}, {7, "sbxd"},
+		{8, "sabxd"}, {9, "sabaxd"},
+	},
+	issuedToSame: {
+		{1, "sad"}, {1, "sfd"}, {4, "safd"}, {4, "sfad"},
+		{5, "saad"}, {5, "sbfd"}, {6, "sxfad"}, {6, "safad"},
+		{6, "saxfd"}, {6, "saxfad"}, {6, "sabfd"}, {7, "saaad"},
+	},
+	issuedToIssued: {
+		{1, "sfad"}, {1, "safd"}, {3, "safad"}, {4, "sxfd"},
+		{5, "saxfd"}, {5, "sxfad"}, {5, "sbfd"}, {6, "saxfad"},
+		{6, "sabfd"}, {7, "saafd"}, {8, "saafad"}, {9, "safaad"},
+	},
+}
+
+func newPaymentType(source data.Currency, amount data.Amount) paymentType {
+	switch {
+	case source.IsNative() && amount.IsNative():
+		return iccToIcc
+	case source.IsNative():
+		return iccToIssued
+	case amount.IsNative():
+		return issuedToIcc
+	case source == amount.Currency:
+		return issuedToSame
+	default:
+		return issuedToIssued
+	}
+}
+
+// pathNode is where the value is held at each step of a path
+type pathNode struct {
+	account  data.Account
+	currency data.Currency
+	line     *data.RippleState // Rippled over to reach the node, nil at the source or after a book
+	book     bool              // Reached through an order book, in which case account is the issuer
+}
+
+func (n pathNode) issue() data.Issue {
+	if n.currency.IsNative() {
+		return data.Issue{}
+	}
+	return data.Issue{Currency: n.currency, Issuer: n.account}
+}
+
+type pathCandidate struct {
+	path data.Path
+	cost *data.Value
+}
+
+// candidateSlice ranks paths as the server does, by their cost and then
+// by their length. Every path carries the whole amount, so unlike the
+// server's ranking their liquidity does not separate them.
+type candidateSlice []pathCandidate
+
+func (s candidateSlice) Len() int      { return len(s) }
+func (s candidateSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s candidateSlice) Less(i, j int) bool {
+	if c := s[i].cost.Compare(*s[j].cost); c != 0 {
+		return c < 0
+	}
+	if len(s[i].path) != len(s[j].path) {
+		return len(s[i].path) < len(s[j].path)
+	}
+	return s[i].path.String() < s[j].path.String()
+}
+
+type currencySlice []data.Currency
+
+func (s currencySlice) Len() int           { return len(s) }
+func (s currencySlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s currencySlice) Less(i, j int) bool { return s[i].Less(s[j]) }
+
+type issueSlice []data.Issue
+
+func (s issueSlice) Len() int           { return len(s) }
+func (s issueSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s issueSlice) Less(i, j int) bool { return s[i].Less(s[j]) }
+
+type pathFinder struct {
+	state  *LedgerState
+	lines  *lineIndex
+	src    data.Account
+	dest   data.Account
+	amount data.Amount
+	found  map[string]pathCandidate
+}
+
+// FindPaths finds ways for src to pay amount to dest without asking a
+// server, using the trust lines in the account state and the order books
+// loaded by FillBooks. An amount issued by dest may be paid with any
+// issuer dest trusts. As the server does, the path implied by the
+// payment is tried and then the types of path for the payment's source
+// and destination currencies, up to the default search level. There is
+// an alternative for each source currency which can pay, with the source
+// amount of its cheapest path. Currencies default to ICC and those of
+// src's trust lines. Unlike the server, each path must be able to deliver
+// the whole amount on its own. The implied path is not returned, but may
+// be the cheapest.
+func (state *LedgerState) FindPaths(src, dest data.Account, amount data.Amount, currencies []data.Currency) ([]PathAlternative, error) {
+	if amount.Value.IsZero() || amount.Value.IsNegative() {
+		return nil, fmt.Errorf("Cannot find paths for %s", amount)
+	}
+	lines, err := state.trustLines()
+	if err != nil {
+		return nil, err
+	}
+	f := &pathFinder{state: state, lines: lines, src: src, dest: dest, amount: amount}
+	if currencies == nil {
+		currencies = f.sourceCurrencies()
+	}
+	var alternatives []PathAlternative
+	for _, currency := range currencies {
+		f.found = make(map[string]pathCandidate)
+		start := []pathNode{{account: src, currency: currency}}
+		types := append([]pathType{{0, "sd"}}, pathTypes[newPaymentType(currency, amount)]...)
+		for _, typ := range types {
+			if typ.level > searchLevel {
+				continue
+			}
+			if err := f.search(start, typ.nodes[1:]); err != nil {
+				return nil, err
+			}
+		}
+		if len(f.found) == 0 {
+			continue
+		}
+		var candidates candidateSlice
+		for _, candidate := range f.found {
+			candidates = append(candidates, candidate)
+		}
+		sort.Sort(candidates)
+		alternative := PathAlternative{
+			SourceAmount: data.Amount{Value: candidates[0].cost, Currency: currency},
+		}
+		if !currency.IsNative() {
+			// The source's own issue allows any issuer
+			alternative.SourceAmount.Issuer = src
+		}
+		for _, candidate := range candidates {
+			if len(candidate.path) > 0 && len(alternative.Paths) < maxPaths {
+				alternative.Paths = append(alternative.Paths, candidate.path)
+			}
+		}
+		alternatives = append(alternatives, alternative)
+	}
+	return alternatives, nil
+}
+
+// lineIndex holds the trust lines in the account state by index, and
+// the indexes of each account's lines in order, so that FindPaths does
+// not walk the whole state each time
+type lineIndex struct {
+	lines    map[data.Hash256]*data.RippleState
+	accounts map[data.Account]hashSlice
+}
+
+// trustLines returns the trust lines, including changes made by
+// ApplyTransaction, reading them from the account state on first use
+func (state *LedgerState) trustLines() (*lineIndex, error) {
+	if state.lines != nil {
+		return state.lines, nil
+	}
+	if err := state.AccountState.Fill(); err != nil {
+		return nil, err
+	}
+	lines := &lineIndex{
+		lines:    make(map[data.Hash256]*data.RippleState),
+		accounts: make(map[data.Account]hashSlice),
+	}
+	err := state.AccountState.Walk(func(key data.Hash256, node *RadixNode) error {
+		line, ok := node.Node.(*data.RippleState)
+		if !ok {
+			return nil
+		}
+		index, err := data.LedgerIndex(line)
+		if err != nil {
+			return err
+		}
+		lines.lines[*index] = line
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for index, le := range state.live {
+		if le == nil {
+			delete(lines.lines, index)
+		} else if line, ok := le.(*data.RippleState); ok {
+			lines.lines[index] = line
+		}
+	}
+	for index, line := range lines.lines {
+		for _, account := range []data.Account{line.LowLimit.Issuer, line.HighLimit.Issuer} {
+			lines.accounts[account] = append(lines.accounts[account], index)
+		}
+	}
+	for _, indexes := range lines.accounts {
+		sort.Sort(indexes)
+	}
+	state.lines = lines
+	return lines, nil
+}
+
+// set adds or replaces the line with index
+func (l *lineIndex) set(index data.Hash256, line *data.RippleState) {
+	if _, ok := l.lines[index]; !ok {
+		for _, account := range []data.Account{line.LowLimit.Issuer, line.HighLimit.Issuer} {
+			indexes := l.accounts[account]
+			i := sort.Search(len(indexes), func(i int) bool { return index.Compare(indexes[i]) <= 0 })
+			indexes = append(indexes, data.Hash256{})
+			copy(indexes[i+1:], indexes[i:])
+			indexes[i] = index
+			l.accounts[account] = indexes
+		}
+	}
+	l.lines[index] = line
+}
+
+// remove removes the line with index
+func (l *lineIndex) remove(index data.Hash256) {
+	line, ok := l.lines[index]
+	if !ok {
+		return
+	}
+	for _, account := range []data.Account{line.LowLimit.Issuer, line.HighLimit.Issuer} {
+		indexes := l.accounts[account]
+		for i := range indexes {
+			if indexes[i] == index {
+				l.accounts[account] = append(indexes[:i], indexes[i+1:]...)
+				break
+			}
+		}
+	}
+	delete(l.lines, index)
+}
+
+// of returns the trust lines of account in order of their index
+func (l *lineIndex) of(account data.Account) []*data.RippleState {
+	var lines []*data.RippleState
+	for _, index := range l.accounts[account] {
+		lines = append(lines, l.lines[index])
+	}
+	return lines
+}
+
+// sourceCurrencies returns ICC and the currencies of src's trust lines
+func (f *pathFinder) sourceCurrencies() []data.Currency {
+	currencies := []data.Currency{{}}
+	seen := make(map[data.Currency]bool)
+	for _, line := range f.lines.of(f.src) {
+		if currency := line.Balance.Currency; !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Sort(currencySlice(currencies[1:]))
+	return currencies
+}
+
+// delivered reports whether the last node holds the amount for dest
+func (f *pathFinder) delivered(nodes []pathNode) bool {
+	last := nodes[len(nodes)-1]
+	if f.amount.IsNative() {
+		return last.currency.IsNative() && (len(nodes) == 1 || last.book)
+	}
+	if last.currency != f.amount.Currency || !last.account.Equals(f.dest) || len(nodes) == 1 {
+		return false
+	}
+	// The issuer must be dest, or have rippled or been bought and rippled to dest
+	previous := nodes[len(nodes)-2]
+	return f.amount.Issuer.Equals(f.dest) || (!last.book && previous.account.Equals(f.amount.Issuer))
+}
+
+// search extends the path to the last node with the remaining nodes of
+// a path type, recording the paths which deliver. Paths which reach the
+// destination early are complete.
+func (f *pathFinder) search(nodes []pathNode, types string) error {
+	if f.delivered(nodes) {
+		return f.record(nodes)
+	}
+	if types == "" {
+		return nil
+	}
+	last := nodes[len(nodes)-1]
+	next := func(n pathNode) error {
+		for _, v := range nodes {
+			if v.account == n.account && v.currency == n.currency {
+				return nil
+			}
+		}
+		return f.search(append(nodes[:len(nodes):len(nodes)], n), types[1:])
+	}
+	switch types[0] {
+	case 'a', 'd':
+		if last.currency.IsNative() {
+			return nil
+		}
+		for _, line := range f.lines.of(last.account) {
+			if line.Balance.Currency != last.currency || line == last.line {
+				continue
+			}
+			other := line.LowLimit.Issuer
+			if other.Equals(last.account) {
+				other = line.HighLimit.Issuer
+			}
+			if types[0] == 'd' && !other.Equals(f.dest) {
+				continue
+			}
+			if !f.canRipple(last, line, other) {
+				continue
+			}
+			if err := next(pathNode{account: other, currency: last.currency, line: line}); err != nil {
+				return err
+			}
+		}
+	case 'b', 'x', 'f':
+		// The source's own issue must ripple to an issuer before it can be sold
+		if !last.currency.IsNative() && last.account.Equals(f.src) {
+			return nil
+		}
+		for _, out := range f.outputs(last.issue()) {
+			switch {
+			case types[0] == 'x' && !out.Currency.IsNative():
+				continue
+			case types[0] == 'f' && out.Currency != f.amount.Currency:
+				continue
+			}
+			if err := next(pathNode{account: out.Issuer, currency: out.Currency, book: true}); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Unknown path node type: %c", types[0])
+	}
+	return nil
+}
+
+// outputs returns the issues with funded offers taking in, in order
+func (f *pathFinder) outputs(in data.Issue) []data.Issue {
+	var outputs []data.Issue
+	for pair := range f.state.Books {
+		var out data.Issue
+		switch {
+		case pair.Counter.Equals(in):
+			out = pair.Base
+		case pair.Base.Equals(in):
+			out = pair.Counter
+		default:
+			continue
+		}
+		for _, o := range f.state.Book(data.NewCurrencyPair(out, in)).side(out) {
+			if o.IsFunded() {
+				outputs = append(outputs, out)
+				break
+			}
+		}
+	}
+	sort.Sort(issueSlice(outputs))
+	return outputs
+}
+
+// side returns the offers selling issue
+func (o Offers) side(issue data.Issue) []BookOffer {
+	if len(o.Asks) > 0 && o.Asks[0].TakerGets.Issue().Equals(issue) {
+		return o.Asks
+	}
+	if len(o.Bids) > 0 && o.Bids[0].TakerGets.Issue().Equals(issue) {
+		return o.Bids
+	}
+	return nil
+}
+
+// canRipple reports whether the value held at from can move over line to
+// to. Lines which are frozen or have no capacity cannot be used, and
+// neither can an intermediate account which has set NoRipple on both
+// the line the value arrived over and line.
+func (f *pathFinder) canRipple(from pathNode, line *data.RippleState, to data.Account) bool {
+	flags := data.LedgerEntryFlag(0)
+	if line.Flags != nil {
+		flags = *line.Flags
+	}
+	if flags&(data.LsLowFreeze|data.LsHighFreeze) != 0 {
+		return false
+	}
+	if capacity, err := lineCapacity(line, from.account, to); err != nil || capacity.IsZero() {
+		return false
+	}
+	if from.line == nil {
+		return true
+	}
+	return !noRipple(from.line, from.account) || !noRipple(line, from.account)
+}
+
+func noRipple(line *data.RippleState, account data.Account) bool {
+	if line.Flags == nil {
+		return false
+	}
+	if line.LowLimit.Issuer.Equals(account) {
+		return *line.Flags&data.LsLowNoRipple != 0
+	}
+	return *line.Flags&data.LsHighNoRipple != 0
+}
+
+// lineCapacity returns how much from can pay to over line, which is what
+// to owes from and then the credit to extends to from
+func lineCapacity(line *data.RippleState, from, to data.Account) (*data.Value, error) {
+	balance, limit := line.Balance.Value, line.HighLimit.Value
+	if line.HighLimit.Issuer.Equals(from) {
+		balance, limit = balance.Negate(), line.LowLimit.Value
+	}
+	capacity, err := balance.Add(*limit)
+	if err != nil {
+		return nil, err
+	}
+	if capacity.IsNegative() {
+		return capacity.ZeroClone(), nil
+	}
+	return capacity, nil
+}
+
+// lineQuality returns account's QualityIn or QualityOut on line
+func lineQuality(line *data.RippleState, account data.Account, in bool) uint32 {
+	low := line.LowLimit.Issuer.Equals(account)
+	var q *uint32
+	switch {
+	case low && in:
+		q = line.LowQualityIn
+	case low:
+		q = line.LowQualityOut
+	case in:
+		q = line.HighQualityIn
+	default:
+		q = line.HighQualityOut
+	}
+	if q == nil {
+		return 0
+	}
+	return *q
+}
+
+// applyQuality multiplies, or divides, v by a quality in billionths
+func applyQuality(v *data.Value, quality uint32, divide bool) (*data.Value, error) {
+	if quality == 0 || quality == 1000000000 {
+		return v, nil
+	}
+	q, err := data.NewNonNativeValue(int64(quality), -9)
+	if err != nil {
+		return nil, err
+	}
+	if divide {
+		return v.DivRound(*q, v.IsNative(), true)
+	}
+	return v.MulRound(*q, v.IsNative(), true)
+}
+
+// record costs the path backwards from dest and keeps it if it is the
+// cheapest with its elements
+func (f *pathFinder) record(nodes []pathNode) error {
+	need := f.amount.Value
+	for k := len(nodes) - 1; k > 0; k-- {
+		from, to := nodes[k-1], nodes[k]
+		var err error
+		if to.book {
+			// Each book of a path is taken alone, the bridge through
+			// ICC being a path of its own
+			q, err := f.state.BuyDirect(data.Amount{Value: need, Currency: to.currency, Issuer: to.issue().Issuer}, from.issue(), nil)
+			if err != nil {
+				return err
+			}
+			if !q.Filled {
+				return nil
+			}
+			need = q.Pays.Value
+			continue
+		}
+		// What to receives is valued at its QualityIn
+		if need, err = applyQuality(need, lineQuality(to.line, to.account, true), true); err != nil {
+			return err
+		}
+		capacity, err := lineCapacity(to.line, from.account, to.account)
+		if err != nil {
+			return err
+		}
+		if capacity.Less(*need) {
+			return nil
+		}
+		if from.line == nil {
+			continue
+		}
+		// Rippling through from costs its QualityOut and transfer fee
+		if need, err = applyQuality(need, lineQuality(to.line, from.account, false), false); err != nil {
+			return err
+		}
+		rate, err := f.state.issuerRate(from.account)
+		if err != nil {
+			return err
+		}
+		if rate != nil {
+			if need, err = need.MulRound(*rate, false, true); err != nil {
+				return err
+			}
+		}
+	}
+	path := data.Path{}
+	for k, n := range nodes[1:] {
+		switch {
+		case n.book:
+			path = path.AddIssue(n.issue())
+		case n.account.Equals(f.dest):
+		case k+2 == len(nodes)-1 && n.account.Equals(f.amount.Issuer) && nodes[k+2].account.Equals(f.dest):
+			// The issuer of the amount is implied
+		default:
+			path = path.AddAccount(n.account)
+		}
+	}
+	key := path.String()
+	if found, ok := f.found[key]; !ok || need.Less(*found.cost) {
+		f.found[key] = pathCandidate{path: path, cost: need}
+	}
+	return nil
+}
@@ -0,0 +1,453 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/wangch/ripple/data"
+)
+
+// Fill is the part of an offer taken by a quote
+type Fill struct {
+	BookOffer
+	Gets    data.Amount // Got from the offer
+	Pays    data.Amount // Paid to the offer, excluding transfer fees
+	Bridged bool        // Taken as one half of an exchange through ICC
+}
+
+// Quote is the result of taking offers, best first, to buy or sell an
+// amount. Prices are the amount paid for one of the amount got, with ICC
+// counted in ICC rather than drips, and include transfer fees.
+type Quote struct {
+	Fills       []Fill
+	Gets        data.Amount
+	Pays        data.Amount // Including TransferFee
+	TransferFee data.Amount
+	Average     *data.Value
+	Worst       *data.Value
+	Filled      bool // Whether all of the amount could be bought or sold
+}
+
+// Buy quotes the cost in pays of getting amount from the order books.
+// Offers at a price worse than limit, if not nil, are not taken.
+func (state *LedgerState) Buy(amount data.Amount, pays data.Issue, limit *data.Value) (*Quote, error) {
+	return state.quote(pays, amount.Issue(), amount.Value, true, true, limit)
+}
+
+// BuyDirect is Buy taking offers only from the book between pays and
+// amount, as a book step of a payment path does, without bridging
+// through ICC
+func (state *LedgerState) BuyDirect(amount data.Amount, pays data.Issue, limit *data.Value) (*Quote, error) {
+	return state.quote(pays, amount.Issue(), amount.Value, true, false, limit)
+}
+
+// Sell quotes what can be got in gets by paying amount, including any
+// transfer fees, to the order books. Offers at a price worse than limit,
+// if not nil, are not taken.
+func (state *LedgerState) Sell(amount data.Amount, gets data.Issue, limit *data.Value) (*Quote, error) {
+	return state.quote(amount.Issue(), gets, amount.Value, false, true, limit)
+}
+
+// leg walks the offers of one side of a book, tracking how much of the
+// current offer remains to be taken
+type leg struct {
+	offers     []BookOffer
+	gets, pays *data.Value
+	quality    *data.Value // Pays per gets, with ICC in drips
+	rate       *data.Value // Transfer rate the taker pays, nil when none
+	native     struct{ gets, pays bool }
+}
+
+func (state *LedgerState) newLeg(pays, gets data.Issue) (*leg, error) {
+	pair := data.NewCurrencyPair(gets, pays)
+	offers := state.Book(pair)
+	l := &leg{offers: offers.Bids}
+	if pair.Base.Equals(gets) {
+		l.offers = offers.Asks
+	}
+	l.native.gets, l.native.pays = gets.IsNative(), pays.IsNative()
+	return l, state.load(l)
+}
+
+// load prepares the first funded offer of the leg to be taken
+func (state *LedgerState) load(l *leg) error {
+	for len(l.offers) > 0 && !l.offers[0].IsFunded() {
+		l.offers = l.offers[1:]
+	}
+	if len(l.offers) == 0 {
+		return nil
+	}
+	o := &l.offers[0]
+	var err error
+	if l.quality, err = offerQuality(&o.Offer); err != nil {
+		return err
+	}
+	if l.rate, err = state.transferRate(*o.Account, *o.TakerPays); err != nil {
+		return err
+	}
+	l.gets, l.pays = o.TakerGetsFunded.Value, o.TakerPaysFunded.Value
+	return nil
+}
+
+func (l *leg) empty() bool {
+	return l == nil || len(l.offers) == 0
+}
+
+// effective returns the quality of the current offer including the
+// transfer fee
+func (l *leg) effective() (*data.Value, error) {
+	if l.rate == nil {
+		return l.quality, nil
+	}
+	return l.quality.MulRound(*l.rate, false, true)
+}
+
+func minValue(a, b *data.Value) *data.Value {
+	if b.Less(*a) {
+		return b
+	}
+	return a
+}
+
+// paysFor returns what the current offer must be paid to get gets
+func (l *leg) paysFor(gets *data.Value) (*data.Value, error) {
+	if !gets.Less(*l.gets) {
+		return l.pays, nil
+	}
+	pays, err := gets.MulRound(*l.quality, l.native.pays, true)
+	if err != nil {
+		return nil, err
+	}
+	return minValue(pays, l.pays), nil
+}
+
+// getsFor returns what the current offer gives for pays
+func (l *leg) getsFor(pays *data.Value) (*data.Value, error) {
+	if !pays.Less(*l.pays) {
+		return l.gets, nil
+	}
+	gets, err := pays.DivRound(*l.quality, l.native.gets, false)
+	if err != nil {
+		return nil, err
+	}
+	return minValue(gets, l.gets), nil
+}
+
+// cost adds the transfer fee to pays
+func (l *leg) cost(pays *data.Value) (*data.Value, error) {
+	if l.rate == nil {
+		return pays, nil
+	}
+	return pays.MulRound(*l.rate, l.native.pays, true)
+}
+
+// spend returns what the current offer can be paid out of cost once
+// the transfer fee is deducted
+func (l *leg) spend(cost *data.Value) (*data.Value, error) {
+	pays := cost
+	if l.rate != nil {
+		var err error
+		if pays, err = cost.DivRound(*l.rate, l.native.pays, false); err != nil {
+			return nil, err
+		}
+	}
+	return minValue(pays, l.pays), nil
+}
+
+// take removes gets and pays from the current offer, moving to the next
+// offer when it is used up
+func (state *LedgerState) take(l *leg, gets, pays *data.Value) error {
+	var err error
+	if l.gets, err = l.gets.Subtract(*gets); err != nil {
+		return err
+	}
+	if l.pays, err = l.pays.Subtract(*pays); err != nil {
+		return err
+	}
+	if l.gets.IsZero() || l.pays.IsZero() {
+		l.offers = l.offers[1:]
+		return state.load(l)
+	}
+	return nil
+}
+
+// exchange is one step of a quote, taking from a single offer or, when
+// icc is set, from two offers through ICC
+type exchange struct {
+	gets, pays *data.Value
+	cost       *data.Value // What the taker pays, including transfer fees
+	icc        *data.Value
+	quality    *data.Value
+}
+
+// exchange takes from the current offer of the leg until remaining, in
+// gets when buying and otherwise in cost, is used up
+func (l *leg) exchange(remaining *data.Value, buy bool) (*exchange, error) {
+	quality, err := l.effective()
+	if err != nil {
+		return nil, err
+	}
+	gets := l.gets
+	if buy {
+		gets = minValue(remaining, l.gets)
+	}
+	pays, err := l.paysFor(gets)
+	if err != nil {
+		return nil, err
+	}
+	cost, err := l.cost(pays)
+	if err != nil {
+		return nil, err
+	}
+	if !buy && remaining.Less(*cost) {
+		cost = remaining
+		if pays, err = l.spend(remaining); err != nil {
+			return nil, err
+		}
+		if gets, err = l.getsFor(pays); err != nil {
+			return nil, err
+		}
+	}
+	return &exchange{gets: gets, pays: pays, cost: cost, quality: quality}, nil
+}
+
+// bridge takes from the current offers of two legs, the first getting
+// ICC and the second paying it
+func bridge(first, second *leg, remaining *data.Value, buy bool) (*exchange, error) {
+	quality, err := first.effective()
+	if err != nil {
+		return nil, err
+	}
+	if quality, err = quality.MulRound(*second.quality, false, true); err != nil {
+		return nil, err
+	}
+	icc := minValue(first.gets, second.pays)
+	gets, err := second.getsFor(icc)
+	if err != nil {
+		return nil, err
+	}
+	if buy && remaining.Less(*gets) {
+		gets = remaining
+		if icc, err = second.paysFor(gets); err != nil {
+			return nil, err
+		}
+		icc = minValue(icc, first.gets)
+	}
+	pays, err := first.paysFor(icc)
+	if err != nil {
+		return nil, err
+	}
+	cost, err := first.cost(pays)
+	if err != nil {
+		return nil, err
+	}
+	if !buy && remaining.Less(*cost) {
+		cost = remaining
+		if pays, err = first.spend(remaining); err != nil {
+			return nil, err
+		}
+		if icc, err = first.getsFor(pays); err != nil {
+			return nil, err
+		}
+		if gets, err = second.getsFor(icc); err != nil {
+			return nil, err
+		}
+	}
+	return &exchange{gets: gets, pays: pays, cost: cost, icc: icc, quality: quality}, nil
+}
+
+// next returns the better of the direct and bridged exchanges, or nil
+// when there are no offers left
+func next(direct, first, second *leg, remaining *data.Value, buy bool) (*exchange, error) {
+	var d, b *exchange
+	var err error
+	if !direct.empty() {
+		if d, err = direct.exchange(remaining, buy); err != nil {
+			return nil, err
+		}
+	}
+	if !first.empty() && !second.empty() {
+		if b, err = bridge(first, second, remaining, buy); err != nil {
+			return nil, err
+		}
+	}
+	if d == nil || (b != nil && b.quality.Less(*d.quality)) {
+		return b, nil
+	}
+	return d, nil
+}
+
+// price converts a quality, with ICC in drips, to a price with ICC in ICC
+func price(quality *data.Value, pays, gets data.Issue) (*data.Value, error) {
+	var shift int64
+	if pays.IsNative() {
+		shift -= 6
+	}
+	if gets.IsNative() {
+		shift += 6
+	}
+	if shift == 0 {
+		return quality, nil
+	}
+	scale, err := data.NewNonNativeValue(1, shift)
+	if err != nil {
+		return nil, err
+	}
+	return quality.Multiply(*scale)
+}
+
+func zeroAmount(issue data.Issue) (*data.Amount, error) {
+	var zero *data.Value
+	var err error
+	if issue.IsNative() {
+		zero, err = data.NewNativeValue(0)
+	} else {
+		zero, err = data.NewNonNativeValue(0, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &data.Amount{Value: zero, Currency: issue.Currency, Issuer: issue.Issuer}, nil
+}
+
+// fill adds what is taken from the current offer of a leg to the quote
+func (q *Quote) fill(l *leg, gets, pays *data.Value, bridged bool) error {
+	o := l.offers[0]
+	i := -1
+	for j := range q.Fills {
+		if q.Fills[j].Index == o.Index {
+			i = j
+		}
+	}
+	if i < 0 {
+		i = len(q.Fills)
+		q.Fills = append(q.Fills, Fill{
+			BookOffer: o,
+			Gets:      data.Amount{Value: gets.ZeroClone(), Currency: o.TakerGets.Currency, Issuer: o.TakerGets.Issuer},
+			Pays:      data.Amount{Value: pays.ZeroClone(), Currency: o.TakerPays.Currency, Issuer: o.TakerPays.Issuer},
+			Bridged:   bridged,
+		})
+	}
+	f := &q.Fills[i]
+	var err error
+	if f.Gets.Value, err = f.Gets.Value.Add(*gets); err != nil {
+		return err
+	}
+	f.Pays.Value, err = f.Pays.Value.Add(*pays)
+	return err
+}
+
+// add adds the totals of a step taken at price p to the quote
+func (q *Quote) add(x *exchange, p *data.Value) error {
+	fee, err := x.cost.Subtract(*x.pays)
+	if err != nil {
+		return err
+	}
+	if q.Gets.Value, err = q.Gets.Value.Add(*x.gets); err != nil {
+		return err
+	}
+	if q.Pays.Value, err = q.Pays.Value.Add(*x.cost); err != nil {
+		return err
+	}
+	if q.TransferFee.Value, err = q.TransferFee.Value.Add(*fee); err != nil {
+		return err
+	}
+	if q.Worst == nil || q.Worst.Less(*p) {
+		q.Worst = p
+	}
+	return nil
+}
+
+// quote takes offers getting gets for pays until amount, which is in
+// gets when buying and otherwise in pays, is used up. When neither side
+// is ICC and bridged is set each step takes whichever is better of the
+// direct book and the two books through ICC, as rippled's auto-bridging
+// does. Offers are
+// funded as FillBooks found them, so funds an owner has in more than one
+// book may be counted more than once.
+func (state *LedgerState) quote(pays, gets data.Issue, amount *data.Value, buy, bridged bool, limit *data.Value) (*Quote, error) {
+	if pays.Equals(gets) {
+		return nil, fmt.Errorf("Cannot quote %s for itself", pays)
+	}
+	if amount.IsNegative() {
+		return nil, fmt.Errorf("Cannot quote a negative amount: %s", amount)
+	}
+	direct, err := state.newLeg(pays, gets)
+	if err != nil {
+		return nil, err
+	}
+	var first, second *leg
+	if bridged && !pays.IsNative() && !gets.IsNative() {
+		if first, err = state.newLeg(pays, data.Issue{}); err != nil {
+			return nil, err
+		}
+		if second, err = state.newLeg(data.Issue{}, gets); err != nil {
+			return nil, err
+		}
+	}
+	q := &Quote{}
+	for _, a := range []struct {
+		amount *data.Amount
+		issue  data.Issue
+	}{{&q.Gets, gets}, {&q.Pays, pays}, {&q.TransferFee, pays}} {
+		zero, err := zeroAmount(a.issue)
+		if err != nil {
+			return nil, err
+		}
+		*a.amount = *zero
+	}
+	remaining := amount
+	for !remaining.IsZero() {
+		x, err := next(direct, first, second, remaining, buy)
+		if err != nil {
+			return nil, err
+		}
+		if x == nil || x.gets.IsZero() {
+			// No offers left or too little remains to be taken
+			break
+		}
+		p, err := price(x.quality, pays, gets)
+		if err != nil {
+			return nil, err
+		}
+		if limit != nil && limit.Less(*p) {
+			break
+		}
+		if x.icc == nil {
+			if err := q.fill(direct, x.gets, x.pays, false); err != nil {
+				return nil, err
+			}
+			err = state.take(direct, x.gets, x.pays)
+		} else {
+			if err := q.fill(first, x.icc, x.pays, true); err != nil {
+				return nil, err
+			}
+			if err := q.fill(second, x.gets, x.icc, true); err != nil {
+				return nil, err
+			}
+			if err := state.take(first, x.icc, x.pays); err != nil {
+				return nil, err
+			}
+			err = state.take(second, x.gets, x.icc)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := q.add(x, p); err != nil {
+			return nil, err
+		}
+		used := x.cost
+		if buy {
+			used = x.gets
+		}
+		if remaining, err = remaining.Subtract(*used); err != nil {
+			return nil, err
+		}
+	}
+	q.Filled = remaining.IsZero()
+	if !q.Gets.IsZero() {
+		if q.Average, err = q.Pays.Value.Ratio(*q.Gets.Value); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
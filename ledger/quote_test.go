@@ -0,0 +1,102 @@
+package ledger
+
+import (
+	"github.com/wangch/ripple/data"
+	. "gopkg.in/check.v1"
+)
+
+type QuoteSuite struct{}
+
+var _ = Suite(&QuoteSuite{})
+
+func checkQuote(c *C, q *Quote, gets, pays, fee, average, worst string, filled bool) {
+	c.Check(q.Gets.Value.String(), Equals, gets)
+	c.Check(q.Pays.Value.String(), Equals, pays)
+	c.Check(q.TransferFee.Value.String(), Equals, fee)
+	c.Check(q.Average.String(), Equals, average)
+	c.Check(q.Worst.String(), Equals, worst)
+	c.Check(q.Filled, Equals, filled)
+}
+
+func (s *QuoteSuite) TestQuote(c *C) {
+	state, _ := booksFixture(c)
+	usd := booksAmount(c, "1/USD/"+gatewayAddress).Issue()
+	icc := data.Issue{}
+
+	q, err := state.Buy(*booksAmount(c, "50/USD/"+gatewayAddress), icc, nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "50", "105", "0", "2.1", "2.5", true)
+	c.Assert(q.Fills, HasLen, 2)
+	c.Check(q.Fills[0].Gets.Value.String(), Equals, "40")
+	c.Check(q.Fills[1].Gets.Value.String(), Equals, "10")
+	c.Check(q.Fills[1].Pays.Value.String(), Equals, "25")
+
+	// More than the books hold
+	q, err = state.Buy(*booksAmount(c, "100/USD/"+gatewayAddress), icc, nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "90", "210", "0", "2.333333333333333", "3", false)
+
+	limit, err := data.NewValue("2.2", false)
+	c.Assert(err, IsNil)
+	q, err = state.Buy(*booksAmount(c, "50/USD/"+gatewayAddress), icc, limit)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "40", "80", "0", "2", "2", false)
+
+	q, err = state.Sell(*booksAmount(c, "100/ICC"), usd, nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "48", "100", "0", "2.083333333333333", "2.5", true)
+
+	// Paying USD to bob costs the transfer fee
+	q, err = state.Sell(*booksAmount(c, "10/USD/"+gatewayAddress), icc, nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "20", "10", "2", "0.5", "0.5", true)
+	c.Check(q.Fills[0].Pays.Value.String(), Equals, "8")
+
+	_, err = state.Buy(*booksAmount(c, "1/ICC"), icc, nil)
+	c.Check(err, ErrorMatches, "Cannot quote ICC for itself")
+}
+
+func (s *QuoteSuite) TestAutoBridge(c *C) {
+	gateway := booksAccount(c, gatewayAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	eur := func(v string) *data.Amount { return booksAmount(c, v+"/EUR/"+gatewayAddress) }
+	icc := func(v string) *data.Amount { return booksAmount(c, v+"/ICC") }
+
+	// Buying EUR through ICC costs 1 USD each, directly 1.2 USD
+	offers := []*data.Offer{
+		bookOffer(c, gateway, 1, usd("12"), eur("10")),
+		bookOffer(c, gateway, 2, usd("50"), icc("100")),
+		bookOffer(c, gateway, 3, icc("20"), eur("10")),
+	}
+	entries := []data.LedgerEntry{
+		feeSettings(20000000, 5000000),
+		booksAccountRoot(gateway, icc("1000"), 3),
+	}
+	for _, offer := range offers {
+		entries = append(entries, offer)
+	}
+	state := synthetic(c, append(entries, bookDirectories(c, offers...)...)...)
+	c.Assert(state.FillBooks(), IsNil)
+
+	q, err := state.Buy(*eur("15"), usd("1").Issue(), nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "15", "16", "0", "1.066666666666666", "1.2", true)
+	c.Assert(q.Fills, HasLen, 3)
+	c.Check(*q.Fills[0].Sequence, Equals, uint32(2))
+	c.Check(q.Fills[0].Gets.Value.String(), Equals, "20")
+	c.Check(q.Fills[0].Pays.Value.String(), Equals, "10")
+	c.Check(q.Fills[0].Bridged, Equals, true)
+	c.Check(*q.Fills[1].Sequence, Equals, uint32(3))
+	c.Check(q.Fills[1].Bridged, Equals, true)
+	c.Check(*q.Fills[2].Sequence, Equals, uint32(1))
+	c.Check(q.Fills[2].Gets.Value.String(), Equals, "5")
+	c.Check(q.Fills[2].Pays.Value.String(), Equals, "6")
+	c.Check(q.Fills[2].Bridged, Equals, false)
+
+	// Without bridging only the direct book is taken
+	q, err = state.BuyDirect(*eur("15"), usd("1").Issue(), nil)
+	c.Assert(err, IsNil)
+	checkQuote(c, q, "10", "12", "0", "1.2", "1.2", false)
+	c.Assert(q.Fills, HasLen, 1)
+	c.Check(*q.Fills[0].Sequence, Equals, uint32(1))
+}
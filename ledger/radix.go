@@ -88,6 +88,55 @@ func (m *RadixMap) walk(f WalkFunc, key data.Hash256, depth uint8, fill bool) er
 	})
 }
 
+// Get returns the ledger entry at index in an account state tree,
+// following the inner nodes from the root and fetching those not yet
+// filled from the database. The leaf found is only the one wanted if
+// its hash, which covers its index, is the one its parent holds.
+func (m *RadixMap) Get(index data.Hash256) (data.LedgerEntry, error) {
+	key := m.root
+	for depth := 0; ; depth++ {
+		if key.IsZero() {
+			return nil, storage.ErrNotFound
+		}
+		node, err := m.node(key)
+		if err != nil {
+			return nil, err
+		}
+		if le, ok := node.(data.LedgerEntry); ok {
+			hash, err := data.LeafHash(le, index)
+			if err != nil {
+				return nil, err
+			}
+			if hash != key {
+				return nil, storage.ErrNotFound
+			}
+			return le, nil
+		}
+		inner, ok := node.(*data.InnerNode)
+		if !ok {
+			return nil, fmt.Errorf("Unexpected %s: %s", node.GetType(), key)
+		}
+		if depth == len(index)*2 {
+			return nil, fmt.Errorf("Too deep: %s", index)
+		}
+		nibble := index[depth/2] >> 4
+		if depth%2 == 1 {
+			nibble = index[depth/2] & 0x0F
+		}
+		key = inner.Children[nibble]
+	}
+}
+
+func (m *RadixMap) node(key data.Hash256) (data.Storer, error) {
+	if node, ok := m.nodes[key]; ok {
+		return node.Node, nil
+	}
+	if m.db == nil {
+		return nil, fmt.Errorf("Missing hash: %s", key.String())
+	}
+	return m.db.Get(key)
+}
+
 func (m *RadixMap) Summary(summary map[string]uint64) error {
 	return m.Walk(func(key data.Hash256, n *RadixNode) error {
 		summary[n.Node.GetType()]++
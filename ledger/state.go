@@ -13,22 +13,16 @@ type AccountState struct {
 	RippleState []data.RippleState
 }
 
-type CurrencyPair struct {
-	Left  data.Currency
-	Right data.Currency
-}
-
-type Offers struct {
-	Asks []data.Offer
-	Bids []data.Offer
-}
-
 type LedgerState struct {
 	*data.Ledger
 	AccountState *RadixMap
 	Transactions *RadixMap
-	Books        map[CurrencyPair]Offers
+	Books        map[data.CurrencyPair]Offers
 	full         bool
+	live         map[data.Hash256]data.LedgerEntry          // Changed by ApplyTransaction, nil when deleted
+	offerBooks   map[data.Hash256]data.CurrencyPair         // The book of each offer in Books
+	involved     map[data.Account]map[data.CurrencyPair]int // Offers in each book owned by or paying out an account's issue
+	lines        *lineIndex                                 // Built by FindPaths
 }
 
 func NewEmptyLedgerState(sequence uint32) *LedgerState {
@@ -36,7 +30,8 @@ func NewEmptyLedgerState(sequence uint32) *LedgerState {
 		Ledger:       data.NewEmptyLedger(sequence),
 		AccountState: NewEmptyRadixMap(),
 		Transactions: NewEmptyRadixMap(),
-		Books:        make(map[CurrencyPair]Offers),
+		Books:        make(map[data.CurrencyPair]Offers),
+		live:         make(map[data.Hash256]data.LedgerEntry),
 	}
 }
 
@@ -53,7 +48,8 @@ func NewLedgerStateFromDB(hash data.Hash256, db storage.DB) (*LedgerState, error
 		Ledger:       ledger,
 		AccountState: NewRadixMap(ledger.StateHash, db),
 		Transactions: NewRadixMap(ledger.TransactionHash, db),
-		Books:        make(map[CurrencyPair]Offers),
+		Books:        make(map[data.CurrencyPair]Offers),
+		live:         make(map[data.Hash256]data.LedgerEntry),
 	}, nil
 }
 
@@ -68,6 +64,18 @@ func (state *LedgerState) Fill() error {
 	return state.Transactions.Fill()
 }
 
+// GetLedgerEntry returns the entry in the account state with index,
+// including the changes made by transactions passed to ApplyTransaction
+func (state *LedgerState) GetLedgerEntry(index data.Hash256) (data.LedgerEntry, error) {
+	if le, ok := state.live[index]; ok {
+		if le == nil {
+			return nil, storage.ErrNotFound
+		}
+		return le, nil
+	}
+	return state.AccountState.Get(index)
+}
+
 func (state *LedgerState) Summary() (string, error) {
 	summary := make(map[string]uint64)
 	var s []string
@@ -82,3 +90,20 @@ func (state *LedgerState) Summary() (string, error) {
 	}
 	return strings.Join(s, ","), nil
 }
+
+// GatewayBalances reports what issuer owes on the trust lines in its
+// owner directory, including changes made by ApplyTransaction
+func (state *LedgerState) GatewayBalances(issuer data.Account, hotWallets []data.Account, top int) (*data.GatewayBalances, error) {
+	g, err := data.NewGatewayBalances(issuer, hotWallets, top)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := data.OwnerDirectory(state, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.AddEntries(entries); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,135 @@
+package ledger
+
+import (
+	"github.com/wangch/ripple/data"
+	"github.com/wangch/ripple/storage"
+	. "gopkg.in/check.v1"
+)
+
+type StateSuite struct{}
+
+var _ = Suite(&StateSuite{})
+
+func (s *StateSuite) TestGetLedgerEntry(c *C) {
+	alice := booksAccountRoot(booksAccount(c, aliceAddress), booksAmount(c, "100/ICC"), 0)
+	bob := booksAccountRoot(booksAccount(c, bobAddress), booksAmount(c, "200/ICC"), 0)
+	state := synthetic(c, alice, bob)
+	aliceIndex, err := data.LedgerIndex(alice)
+	c.Assert(err, IsNil)
+	bobIndex, err := data.LedgerIndex(bob)
+	c.Assert(err, IsNil)
+	c.Assert(aliceIndex[0]>>4, Not(Equals), bobIndex[0]>>4)
+
+	le, err := state.GetLedgerEntry(*aliceIndex)
+	c.Assert(err, IsNil)
+	c.Check(le.(*data.AccountRoot).Account, DeepEquals, alice.Account)
+	le, err = state.GetLedgerEntry(*bobIndex)
+	c.Assert(err, IsNil)
+	c.Check(le.(*data.AccountRoot).Account, DeepEquals, bob.Account)
+
+	// Leads to alice's leaf, which is not the entry wanted
+	missing := *aliceIndex
+	missing[31]++
+	_, err = state.GetLedgerEntry(missing)
+	c.Check(err, Equals, storage.ErrNotFound)
+
+	// Leads to an empty branch
+	for missing[0] = 0; missing[0]>>4 == aliceIndex[0]>>4 || missing[0]>>4 == bobIndex[0]>>4; missing[0] += 0x10 {
+	}
+	_, err = state.GetLedgerEntry(missing)
+	c.Check(err, Equals, storage.ErrNotFound)
+
+	// A leaf which no longer matches its hash
+	*bob.Balance = *booksAmount(c, "300/ICC").Value
+	_, err = state.GetLedgerEntry(*bobIndex)
+	c.Check(err, Equals, storage.ErrNotFound)
+}
+
+// directoryPage returns a page of the owner directory with root
+func directoryPage(c *C, root data.Hash256, page, previous, next uint64, indexes ...data.Hash256) (data.Hash256, *data.Directory) {
+	p, prev, n := data.NodeIndex(page), data.NodeIndex(previous), data.NodeIndex(next)
+	index := root
+	if page > 0 {
+		i, err := data.GetDirectoryNodeIndex(root, &p)
+		c.Assert(err, IsNil)
+		index = *i
+	}
+	dir := data.LedgerEntryFactory[data.DIRECTORY]().(*data.Directory)
+	vector := data.Vector256(indexes)
+	dir.RootIndex, dir.Indexes, dir.IndexPrevious, dir.IndexNext = &root, &vector, &prev, &n
+	return index, dir
+}
+
+func (s *StateSuite) TestOwnerDirectory(c *C) {
+	alice := booksAccount(c, aliceAddress)
+	root, err := data.GetOwnerDirectoryIndex(alice)
+	c.Assert(err, IsNil)
+	leaves := map[data.Hash256]data.LedgerEntry{}
+	var offers []data.Hash256
+	for seq := uint32(1); seq <= 5; seq++ {
+		offer := bookOffer(c, alice, seq, booksAmount(c, "10/ICC"), booksAmount(c, "1/USD/"+gatewayAddress))
+		index, err := data.LedgerIndex(offer)
+		c.Assert(err, IsNil)
+		leaves[*index] = offer
+		offers = append(offers, *index)
+	}
+	// Pages 1, 2 and 4 have been deleted, so page 5 follows page 3
+	pages := []struct {
+		page, previous, next uint64
+		indexes              []data.Hash256
+	}{
+		{0, 5, 3, offers[:2]},
+		{3, 0, 5, offers[2:4]},
+		{5, 3, 0, offers[4:]},
+	}
+	for _, p := range pages {
+		index, dir := directoryPage(c, *root, p.page, p.previous, p.next, p.indexes...)
+		leaves[index] = dir
+	}
+	state := syntheticAt(c, leaves)
+
+	entries, err := data.OwnerDirectory(state, alice)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, len(offers))
+	for i, le := range entries {
+		c.Check(*le.(*data.Offer).Sequence, Equals, uint32(i+1))
+	}
+}
+
+func (s *StateSuite) TestGatewayBalances(c *C) {
+	gateway := booksAccount(c, gatewayAddress)
+	alice := booksAccount(c, aliceAddress)
+	bob := booksAccount(c, bobAddress)
+	usd := func(v string) *data.Amount { return booksAmount(c, v+"/USD/"+gatewayAddress) }
+	leaves := map[data.Hash256]data.LedgerEntry{}
+	var owned []data.Hash256
+	for _, le := range []data.LedgerEntry{
+		booksAccountRoot(gateway, booksAmount(c, "1000/ICC"), 0),
+		trustLine(alice, usd("40")),
+		trustLine(bob, usd("60")),
+	} {
+		index, err := data.LedgerIndex(le)
+		c.Assert(err, IsNil)
+		leaves[*index] = le
+		if le.GetLedgerEntryType() == data.RIPPLE_STATE {
+			owned = append(owned, *index)
+		}
+	}
+	root, err := data.GetOwnerDirectoryIndex(gateway)
+	c.Assert(err, IsNil)
+	_, dir := directoryPage(c, *root, 0, 0, 0, owned...)
+	leaves[*root] = dir
+	state := syntheticAt(c, leaves)
+
+	g, err := state.GatewayBalances(gateway, []data.Account{bob}, 10)
+	c.Assert(err, IsNil)
+	obligations, err := g.Obligations()
+	c.Assert(err, IsNil)
+	c.Assert(obligations, HasLen, 1)
+	c.Check(obligations[0].Total.String(), Equals, "40")
+	c.Check(obligations[0].Holders, Equals, 1)
+	c.Check(obligations[0].HotWalletTotal.String(), Equals, "60")
+
+	_, err = state.GatewayBalances(gateway, nil, -1)
+	c.Check(err, ErrorMatches, "Negative number of top holders: -1")
+}
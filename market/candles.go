@@ -0,0 +1,367 @@
+// Package market aggregates the trades in validated transactions into
+// OHLCV candles for each currency pair.
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wangch/ripple/data"
+)
+
+var one, _ = data.NewNonNativeValue(1, 0)
+
+// Candle summarises the trades in a pair over one interval. Prices are
+// the amount of the pair's Counter for one unit of its Base and ICC is
+// counted at face value rather than in drips.
+type Candle struct {
+	Pair          data.CurrencyPair
+	Start         data.RippleTime
+	Interval      time.Duration
+	Open          *data.Value
+	High          *data.Value
+	Low           *data.Value
+	Close         *data.Value
+	Volume        *data.Value // Of the Base
+	CounterVolume *data.Value // Of the Counter
+	Trades        int
+	first, last   uint64 // Ledger sequence and transaction index of Open and Close
+}
+
+func (c Candle) String() string {
+	return fmt.Sprintf("%s %s %s O: %s H: %s L: %s C: %s V: %s Trades: %d", c.Pair, c.Start, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.Trades)
+}
+
+// VWAP returns the volume weighted average price of the candle
+func (c Candle) VWAP() (*data.Value, error) {
+	return c.CounterVolume.Divide(*c.Volume)
+}
+
+// End returns the start of the following candle
+func (c Candle) End() data.RippleTime {
+	return *data.NewRippleTime(c.Start.Uint32() + uint32(c.Interval/time.Second))
+}
+
+// Inverse returns the candle with its pair and prices inverted
+func (c Candle) Inverse() (*Candle, error) {
+	inverse := c
+	inverse.Pair = c.Pair.Inverse()
+	inverse.Volume, inverse.CounterVolume = c.CounterVolume, c.Volume
+	for _, price := range []struct {
+		to   **data.Value
+		from *data.Value
+	}{
+		{&inverse.Open, c.Open},
+		{&inverse.High, c.Low},
+		{&inverse.Low, c.High},
+		{&inverse.Close, c.Close},
+	} {
+		var err error
+		if *price.to, err = one.Divide(*price.from); err != nil {
+			return nil, err
+		}
+	}
+	return &inverse, nil
+}
+
+// add includes a trade in the candle
+func (c *Candle) add(price, base, counter *data.Value, position uint64) error {
+	if c.Trades == 0 {
+		c.Open, c.High, c.Low, c.Close = price, price, price, price
+		c.Volume, c.CounterVolume = base, counter
+		c.first, c.last, c.Trades = position, position, 1
+		return nil
+	}
+	var err error
+	if c.Volume, err = c.Volume.Add(*base); err != nil {
+		return err
+	}
+	if c.CounterVolume, err = c.CounterVolume.Add(*counter); err != nil {
+		return err
+	}
+	if price.Less(*c.Low) {
+		c.Low = price
+	}
+	if c.High.Less(*price) {
+		c.High = price
+	}
+	// Transactions may arrive out of order when backfilling
+	if position < c.first {
+		c.Open, c.first = price, position
+	}
+	if position >= c.last {
+		c.Close, c.last = price, position
+	}
+	c.Trades++
+	return nil
+}
+
+type CandleSlice []Candle
+
+func (s CandleSlice) Len() int           { return len(s) }
+func (s CandleSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s CandleSlice) Less(i, j int) bool { return s[i].Start.Uint32() < s[j].Start.Uint32() }
+
+type pairSlice []data.CurrencyPair
+
+func (s pairSlice) Len() int           { return len(s) }
+func (s pairSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s pairSlice) Less(i, j int) bool { return s[i].Less(s[j]) }
+
+type seriesKey struct {
+	pair     data.CurrencyPair
+	interval time.Duration
+}
+
+// Aggregator maintains candles at each of its intervals for every pair
+// traded in the transactions added to it. It is safe for concurrent use.
+type Aggregator struct {
+	intervals []time.Duration
+	series    map[seriesKey]map[uint32]*Candle
+	mu        sync.RWMutex
+}
+
+// NewAggregator returns an Aggregator for intervals, which must be
+// whole numbers of seconds
+func NewAggregator(intervals ...time.Duration) (*Aggregator, error) {
+	if len(intervals) == 0 {
+		return nil, fmt.Errorf("No intervals")
+	}
+	for _, interval := range intervals {
+		if interval < time.Second || interval%time.Second != 0 {
+			return nil, fmt.Errorf("Bad interval: %s", interval)
+		}
+	}
+	return &Aggregator{
+		intervals: intervals,
+		series:    make(map[seriesKey]map[uint32]*Candle),
+	}, nil
+}
+
+// Add includes the trades in a validated transaction. Each transaction
+// should only be added once. The transaction's Date is required.
+func (a *Aggregator) Add(txm *data.TransactionWithMetaData) error {
+	if !txm.MetaData.TransactionResult.Success() {
+		return nil
+	}
+	trades, err := txm.Trades()
+	if err != nil {
+		return err
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+	if txm.Date == nil {
+		return fmt.Errorf("Transaction %s has no date", txm.GetHash())
+	}
+	position := uint64(txm.LedgerSequence)<<32 | uint64(txm.MetaData.TransactionIndex)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, trade := range trades {
+		if err := a.add(trade, *txm.Date, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Aggregator) add(trade data.Trade, at data.RippleTime, position uint64) error {
+	price := trade.Price()
+	if price == nil {
+		return fmt.Errorf("No price for trade: %s", trade)
+	}
+	base, err := faceValue(trade.Base())
+	if err != nil {
+		return err
+	}
+	counter, err := faceValue(trade.Counter())
+	if err != nil {
+		return err
+	}
+	for _, interval := range a.intervals {
+		key := seriesKey{trade.Pair, interval}
+		if a.series[key] == nil {
+			a.series[key] = make(map[uint32]*Candle)
+		}
+		seconds := uint32(interval / time.Second)
+		start := at.Uint32() - at.Uint32()%seconds
+		candle := a.series[key][start]
+		if candle == nil {
+			candle = &Candle{Pair: trade.Pair, Start: *data.NewRippleTime(start), Interval: interval}
+			a.series[key][start] = candle
+		}
+		if err := candle.add(price, base, counter, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// faceValue returns the amount as a non-native value, with ICC in ICC
+func faceValue(amount data.Amount) (*data.Value, error) {
+	return amount.Value.Ratio(*one)
+}
+
+// Pairs returns every pair that has been traded, in their usual orientation
+func (a *Aggregator) Pairs() []data.CurrencyPair {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var pairs []data.CurrencyPair
+	for key := range a.series {
+		if key.interval == a.intervals[0] {
+			pairs = append(pairs, key.pair)
+		}
+	}
+	sort.Sort(pairSlice(pairs))
+	return pairs
+}
+
+// Candles returns the candles of pair at interval which start at or
+// after from and before to, oldest first. Intervals without trades are
+// omitted. The pair may be in either orientation.
+func (a *Aggregator) Candles(pair data.CurrencyPair, interval time.Duration, from, to data.RippleTime) (CandleSlice, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if !a.hasInterval(interval) {
+		return nil, fmt.Errorf("Unknown interval: %s", interval)
+	}
+	inverse := false
+	series := a.series[seriesKey{pair, interval}]
+	if series == nil {
+		series, inverse = a.series[seriesKey{pair.Inverse(), interval}], true
+	}
+	var candles CandleSlice
+	for start, candle := range series {
+		if start < from.Uint32() || start >= to.Uint32() {
+			continue
+		}
+		if !inverse {
+			candles = append(candles, *candle)
+			continue
+		}
+		inverted, err := candle.Inverse()
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, *inverted)
+	}
+	sort.Sort(candles)
+	return candles, nil
+}
+
+func (a *Aggregator) hasInterval(interval time.Duration) bool {
+	for _, i := range a.intervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+type candleJSON struct {
+	Base          data.Issue
+	Counter       data.Issue
+	Start         data.RippleTime
+	Interval      uint32 // Seconds
+	Open          string
+	High          string
+	Low           string
+	Close         string
+	Volume        string
+	CounterVolume string
+	Trades        int
+	First         uint64
+	Last          uint64
+}
+
+type snapshotJSON struct {
+	Intervals []uint32 // Seconds
+	Candles   []candleJSON
+}
+
+// Snapshot writes every candle to w so that they can be restored
+func (a *Aggregator) Snapshot(w io.Writer) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var snapshot snapshotJSON
+	for _, interval := range a.intervals {
+		snapshot.Intervals = append(snapshot.Intervals, uint32(interval/time.Second))
+	}
+	for _, series := range a.series {
+		for _, c := range series {
+			snapshot.Candles = append(snapshot.Candles, candleJSON{
+				Base:          c.Pair.Base,
+				Counter:       c.Pair.Counter,
+				Start:         c.Start,
+				Interval:      uint32(c.Interval / time.Second),
+				Open:          c.Open.String(),
+				High:          c.High.String(),
+				Low:           c.Low.String(),
+				Close:         c.Close.String(),
+				Volume:        c.Volume.String(),
+				CounterVolume: c.CounterVolume.String(),
+				Trades:        c.Trades,
+				First:         c.first,
+				Last:          c.last,
+			})
+		}
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// Restore replaces every candle with those in a snapshot, which must
+// have been taken by an Aggregator with the same intervals
+func (a *Aggregator) Restore(r io.Reader) error {
+	var snapshot snapshotJSON
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return err
+	}
+	if len(snapshot.Intervals) != len(a.intervals) {
+		return fmt.Errorf("Snapshot intervals %v do not match", snapshot.Intervals)
+	}
+	for i, interval := range snapshot.Intervals {
+		if time.Duration(interval)*time.Second != a.intervals[i] {
+			return fmt.Errorf("Snapshot intervals %v do not match", snapshot.Intervals)
+		}
+	}
+	series := make(map[seriesKey]map[uint32]*Candle)
+	for _, c := range snapshot.Candles {
+		candle := &Candle{
+			Pair:     data.CurrencyPair{Base: c.Base, Counter: c.Counter},
+			Start:    c.Start,
+			Interval: time.Duration(c.Interval) * time.Second,
+			Trades:   c.Trades,
+			first:    c.First,
+			last:     c.Last,
+		}
+		for _, v := range []struct {
+			to   **data.Value
+			from string
+		}{
+			{&candle.Open, c.Open},
+			{&candle.High, c.High},
+			{&candle.Low, c.Low},
+			{&candle.Close, c.Close},
+			{&candle.Volume, c.Volume},
+			{&candle.CounterVolume, c.CounterVolume},
+		} {
+			var err error
+			if *v.to, err = data.NewValue(v.from, false); err != nil {
+				return err
+			}
+		}
+		key := seriesKey{candle.Pair, candle.Interval}
+		if series[key] == nil {
+			series[key] = make(map[uint32]*Candle)
+		}
+		series[key][candle.Start.Uint32()] = candle
+	}
+	a.mu.Lock()
+	a.series = series
+	a.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,128 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wangch/ripple/data"
+	internal "github.com/wangch/ripple/testing"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CandleSuite struct{}
+
+var _ = Suite(&CandleSuite{})
+
+// readTransactionFixture loads a transaction from the data package's
+// testdata, adding date
+func readTransactionFixture(c *C, file string, date uint32) *data.TransactionWithMetaData {
+	b, err := internal.ReadFixture("../data/testdata/" + file)
+	c.Assert(err, IsNil)
+	b = bytes.Replace(b, []byte(`"TransactionType"`), []byte(fmt.Sprintf(`"date": %d, "TransactionType"`, date)), 1)
+	var txm data.TransactionWithMetaData
+	c.Assert(json.Unmarshal(b, &txm), IsNil)
+	c.Assert(txm.Date, NotNil)
+	c.Assert(txm.Date.Uint32(), Equals, date)
+	return &txm
+}
+
+func issue(c *C, s string) data.Issue {
+	amount, err := data.NewAmount("0/" + s)
+	c.Assert(err, IsNil)
+	return amount.Issue()
+}
+
+func (s *CandleSuite) TestAggregator(c *C) {
+	_, err := NewAggregator(time.Millisecond)
+	c.Check(err, ErrorMatches, "Bad interval: 1ms")
+
+	agg, err := NewAggregator(time.Minute, time.Hour)
+	c.Assert(err, IsNil)
+	txm := readTransactionFixture(c, "transaction_offercreate.json", 454971490)
+	c.Assert(agg.Add(txm), IsNil)
+
+	btc := data.CurrencyPair{Base: issue(c, "BTC/ivYAfWj5gh67oV6fW32ZzP3Aw4Eubs59B"), Counter: issue(c, "ICC")}
+	c.Check(agg.Pairs(), DeepEquals, []data.CurrencyPair{btc})
+
+	candles, err := agg.Candles(btc, time.Minute, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+	c.Assert(err, IsNil)
+	c.Assert(candles, HasLen, 1)
+	candle := candles[0]
+	c.Check(candle.Start.Uint32(), Equals, uint32(454971480))
+	c.Check(candle.End().Uint32(), Equals, uint32(454971540))
+	c.Check(candle.Trades, Equals, 8)
+	c.Check(candle.Low.String(), Equals, "63998.00000186701")
+	c.Check(candle.High.String(), Equals, "64891.6849763283")
+	c.Check(candle.Volume.String(), Equals, "8")
+	vwap, err := candle.VWAP()
+	c.Assert(err, IsNil)
+	c.Check(vwap.String(), Equals, "64552.313597875")
+
+	inverse, err := agg.Candles(btc.Inverse(), time.Hour, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+	c.Assert(err, IsNil)
+	c.Assert(inverse, HasLen, 1)
+	c.Check(inverse[0].Start.Uint32(), Equals, uint32(454968000))
+	c.Check(inverse[0].Volume.Equals(*candle.CounterVolume), Equals, true)
+	c.Check(inverse[0].High.String(), Equals, "0.00001562548829605342")
+
+	_, err = agg.Candles(btc, time.Second, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+	c.Check(err, ErrorMatches, "Unknown interval: 1s")
+
+	// Transactions with trades need a date
+	txm.Date = nil
+	c.Check(agg.Add(txm), ErrorMatches, "Transaction .* has no date")
+}
+
+func (s *CandleSuite) TestOpenClose(c *C) {
+	agg, err := NewAggregator(time.Minute)
+	c.Assert(err, IsNil)
+	later := readTransactionFixture(c, "transaction_offercreate.json", 454971490)
+	earlier := readTransactionFixture(c, "transaction_offercreate.json", 454971485)
+	earlier.LedgerSequence--
+	trades, err := earlier.Trades()
+	c.Assert(err, IsNil)
+	// The earlier trade arrives last, as when backfilling
+	c.Assert(agg.add(trades[0], *later.Date, uint64(later.LedgerSequence)<<32), IsNil)
+	c.Assert(agg.add(trades[7], *earlier.Date, uint64(earlier.LedgerSequence)<<32), IsNil)
+
+	candles, err := agg.Candles(trades[0].Pair, time.Minute, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+	c.Assert(err, IsNil)
+	c.Assert(candles, HasLen, 1)
+	c.Check(candles[0].Open.Equals(*trades[7].Price()), Equals, true)
+	c.Check(candles[0].Close.Equals(*trades[0].Price()), Equals, true)
+}
+
+func (s *CandleSuite) TestSnapshot(c *C) {
+	agg, err := NewAggregator(time.Minute, time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(agg.Add(readTransactionFixture(c, "transaction_offercreate.json", 454971490)), IsNil)
+	var b bytes.Buffer
+	c.Assert(agg.Snapshot(&b), IsNil)
+	snapshot := b.String()
+
+	restored, err := NewAggregator(time.Minute, time.Hour)
+	c.Assert(err, IsNil)
+	c.Assert(restored.Restore(strings.NewReader(snapshot)), IsNil)
+	for _, interval := range []time.Duration{time.Minute, time.Hour} {
+		pair := agg.Pairs()[0]
+		expected, err := agg.Candles(pair, interval, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+		c.Assert(err, IsNil)
+		obtained, err := restored.Candles(pair, interval, *data.NewRippleTime(0), *data.NewRippleTime(1 << 31))
+		c.Assert(err, IsNil)
+		c.Check(obtained, HasLen, len(expected))
+		for i := range obtained {
+			c.Check(obtained[i].String(), Equals, expected[i].String())
+			c.Check(obtained[i].CounterVolume.Equals(*expected[i].CounterVolume), Equals, true)
+		}
+	}
+
+	other, err := NewAggregator(time.Minute)
+	c.Assert(err, IsNil)
+	c.Check(other.Restore(strings.NewReader(snapshot)), ErrorMatches, "Snapshot intervals .* do not match")
+}
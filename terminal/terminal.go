@@ -4,6 +4,7 @@ package terminal
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/fatih/color"
 	"github.com/wangch/ripple/data"
@@ -32,6 +33,7 @@ var (
 	tradeStyle      = color.New(color.FgBlue)
 	balanceStyle    = color.New(color.FgMagenta)
 	pathStyle       = color.New(color.FgYellow)
+	memoStyle       = color.New(color.FgCyan)
 	infoStyle       = color.New(color.FgRed)
 )
 
@@ -80,6 +82,15 @@ func newLeBundle(v interface{}, flag Flag) (*bundle, error) {
 	case *data.Amendments:
 		format += "%s"
 		values = append(values, []interface{}{le.Amendments}...)
+	case *data.Preauthorization:
+		format += "%-34s => %-34s"
+		values = append(values, []interface{}{le.Account, le.Authorize}...)
+	case *data.NFTokenPage:
+		format += "%d"
+		values = append(values, []interface{}{len(le.NFTokens)}...)
+	case *data.NFTokenOffer:
+		format += "%-34s %s %-60s"
+		values = append(values, []interface{}{le.Owner, le.NFTokenID, le.Amount}...)
 	default:
 		return nil, fmt.Errorf("Unknown Ledger Entry Type")
 	}
@@ -116,11 +127,46 @@ func newTxBundle(v data.Transaction, insert string, flag Flag) (*bundle, error)
 		format += "%-9d"
 		values = append(values, tx.Sequence)
 	case *data.AccountSet:
-		format += "%-9d"
-		values = append(values, tx.Sequence)
+		format += "%-9d %s"
+		values = append(values, tx.Sequence, strings.Join(tx.ExplainFlags(), " "))
 	case *data.TrustSet:
 		format += "%-60s %d %d"
 		values = append(values, tx.LimitAmount, tx.QualityIn, tx.QualityOut)
+	case *data.DepositPreauth:
+		switch {
+		case tx.Authorize != nil:
+			format += "+ %-34s"
+			values = append(values, tx.Authorize)
+		case tx.Unauthorize != nil:
+			format += "- %-34s"
+			values = append(values, tx.Unauthorize)
+		}
+	case *data.AccountDelete:
+		format += "=> %-34s"
+		values = append(values, tx.Destination)
+		if tx.DestinationTag != nil {
+			format += " %d"
+			values = append(values, *tx.DestinationTag)
+		}
+	case *data.NFTokenMint:
+		format += "%-9d"
+		values = append(values, tx.NFTokenTaxon)
+	case *data.NFTokenBurn:
+		format += "%s"
+		values = append(values, tx.NFTokenID)
+	case *data.NFTokenCreateOffer:
+		format += "%s %-60s"
+		values = append(values, tx.NFTokenID, tx.Amount)
+	case *data.NFTokenCancelOffer:
+		format += "%d"
+		values = append(values, len(tx.NFTokenOffers))
+	case *data.NFTokenAcceptOffer:
+		format += "%s %s"
+		values = append(values, tx.NFTokenSellOffer, tx.NFTokenBuyOffer)
+	}
+	for _, memo := range base.Memos {
+		format += " ✐ %s"
+		values = append(values, memo)
 	}
 	return &bundle{
 		color:  txStyle,
@@ -203,8 +249,15 @@ func newBundle(value interface{}, flag Flag) (*bundle, error) {
 	case data.Trade:
 		return &bundle{
 			color:  tradeStyle,
-			format: "Trade: %-34s => %-34s %-18s %60s => %-60s",
-			values: []interface{}{v.Seller, v.Buyer, v.Price(), v.Paid, v.Got},
+			format: "Trade: %-4s %-34s => %-34s %-18s %60s => %-60s",
+			values: []interface{}{v.Side, v.Maker, v.Taker, v.Price(), v.Paid, v.Got},
+			flag:   flag,
+		}, nil
+	case data.Memo:
+		return &bundle{
+			color:  memoStyle,
+			format: "Memo: %s",
+			values: []interface{}{v},
 			flag:   flag,
 		}, nil
 	case data.Balance:
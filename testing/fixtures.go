@@ -0,0 +1,22 @@
+package testing
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+var rippleAddress = regexp.MustCompile(`"r[1-9A-HJ-NP-Za-km-z]{24,34}"`)
+
+// ReadFixture reads a file taken from the Ripple network, whose address
+// alphabet has 'r' and 'i' swapped, and swaps them back in its addresses
+func ReadFixture(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	swap := strings.NewReplacer("r", "i", "i", "r")
+	return rippleAddress.ReplaceAllFunc(b, func(address []byte) []byte {
+		return []byte(swap.Replace(string(address)))
+	}), nil
+}
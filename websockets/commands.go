@@ -210,6 +210,20 @@ type BinaryLedgerDataResult struct {
 	State          []BinaryLedgerData `json:"state"`
 }
 
+type LedgerEntryCommand struct {
+	*Command
+	Ledger interface{}        `json:"ledger_index,omitempty"`
+	Index  data.Hash256       `json:"index"`
+	Binary bool               `json:"binary"`
+	Result *LedgerEntryResult `json:"result,omitempty"`
+}
+
+type LedgerEntryResult struct {
+	LedgerSequence uint32       `json:"ledger_index"`
+	Index          data.Hash256 `json:"index"`
+	NodeBinary     string       `json:"node_binary"`
+}
+
 type RipplePathFindCommand struct {
 	*Command
 	SrcAccount    data.Account          `json:"source_account"`
@@ -208,13 +208,13 @@ func (r *Remote) SubmitWithSign(ptx *PaymentTx, secret string) (*SubmitResult, e
 
 // Synchronously submit a single transaction
 func (r *Remote) Submit(tx data.Transaction) (*SubmitResult, error) {
-	_, raw, err := data.Raw(tx)
+	hashes, err := data.AllHashes(tx)
 	if err != nil {
 		return nil, err
 	}
 	cmd := &SubmitCommand{
 		Command: newCommand("submit"),
-		TxBlob:  fmt.Sprintf("%X", raw),
+		TxBlob:  fmt.Sprintf("%X", hashes.Raw),
 	}
 	r.outgoing <- cmd
 	<-cmd.Ready
@@ -229,13 +229,13 @@ func (r *Remote) SubmitBatch(txs []data.Transaction) ([]*SubmitResult, error) {
 	commands := make([]*SubmitCommand, len(txs))
 	results := make([]*SubmitResult, len(txs))
 	for i := range txs {
-		_, raw, err := data.Raw(txs[i])
+		hashes, err := data.AllHashes(txs[i])
 		if err != nil {
 			return nil, err
 		}
 		cmd := &SubmitCommand{
 			Command: newCommand("submit"),
-			TxBlob:  fmt.Sprintf("%X", raw),
+			TxBlob:  fmt.Sprintf("%X", hashes.Raw),
 		}
 		r.outgoing <- cmd
 		commands[i] = cmd
@@ -299,6 +299,41 @@ func (r *Remote) StreamLedgerData(ledger interface{}) chan data.LedgerEntrySlice
 	return c
 }
 
+// Synchronously gets the ledger entry with index, using the binary form
+func (r *Remote) LedgerEntry(ledger interface{}, index data.Hash256) (data.LedgerEntry, error) {
+	cmd := &LedgerEntryCommand{
+		Command: newCommand("ledger_entry"),
+		Ledger:  ledger,
+		Index:   index,
+		Binary:  true,
+	}
+	r.outgoing <- cmd
+	<-cmd.Ready
+	if cmd.CommandError != nil {
+		return nil, cmd.CommandError
+	}
+	b, err := hex.DecodeString(cmd.Result.NodeBinary + cmd.Result.Index.String())
+	if err != nil {
+		return nil, err
+	}
+	return data.ReadLedgerEntry(bytes.NewReader(b), data.Hash256{})
+}
+
+type remoteLedger struct {
+	remote *Remote
+	ledger interface{}
+}
+
+func (l remoteLedger) GetLedgerEntry(index data.Hash256) (data.LedgerEntry, error) {
+	return l.remote.LedgerEntry(l.ledger, index)
+}
+
+// LedgerEntries looks up ledger entries in a ledger on demand, so that
+// directories can be walked without fetching the whole ledger
+func (r *Remote) LedgerEntries(ledger interface{}) data.LedgerEntryGetter {
+	return remoteLedger{r, ledger}
+}
+
 // Synchronously gets a single ledger
 func (r *Remote) Ledger(ledger interface{}, transactions bool) (*LedgerResult, error) {
 	cmd := &LedgerCommand{
@@ -20,6 +20,11 @@ type LedgerStreamMsg struct {
 	TxnCount         uint32          `json:"txn_count"` // Only streamed, not in the subscribe result.
 }
 
+// Reserve returns the reserve in force as of the ledger
+func (msg *LedgerStreamMsg) Reserve() (*data.Reserve, error) {
+	return data.NewReserve(msg.ReserveBase, msg.ReserveIncrement)
+}
+
 // Fields from subscribed transaction stream messages
 type TransactionStreamMsg struct {
 	Transaction         data.TransactionWithMetaData `json:"transaction"`